@@ -0,0 +1,393 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiState keeps what the monitor learned in recent intervals (latest
+// offenders, per-digest history, firing alerts, snapshot health) and serves it
+// as a versioned JSON API under /api/v1/.
+type apiState struct {
+	cfg       Config
+	reporters []string
+	started   time.Time
+	history   int // intervals kept per digest
+
+	mu        sync.RWMutex
+	latest    []apiOffender
+	latestAt  time.Time
+	elapsed   time.Duration
+	digests   map[snapKey]*apiDigest
+	firing    map[snapKey]*apiAlert
+	intervals uint64
+	engine    *engineSample
+	health    apiHealth
+}
+
+// apiOffender is one digest of the latest interval.
+type apiOffender struct {
+	Schema string `json:"schema"`
+	Digest string `json:"digest"`
+	Sample string `json:"sample"`
+	apiCounts
+	BytesPerSec float64 `json:"bytesPerSec"`
+	Alerting    bool    `json:"alerting"`
+	Silenced    bool    `json:"silenced"` // over a threshold but muted by a silence
+}
+
+// apiCounts are a digest's counters over some span.
+type apiCounts struct {
+	Count        uint64 `json:"count"`
+	RowsExamined uint64 `json:"rowsExamined"`
+	RowsSent     uint64 `json:"rowsSent"`
+	RowsAffected uint64 `json:"rowsAffected"`
+	BytesRead    uint64 `json:"bytesRead"`
+	BytesWrite   uint64 `json:"bytesWrite"`
+}
+
+func (c *apiCounts) add(o apiCounts) {
+	c.Count += o.Count
+	c.RowsExamined += o.RowsExamined
+	c.RowsSent += o.RowsSent
+	c.RowsAffected += o.RowsAffected
+	c.BytesRead += o.BytesRead
+	c.BytesWrite += o.BytesWrite
+}
+
+// apiInterval is one point of a digest's history.
+type apiInterval struct {
+	At time.Time `json:"at"`
+	apiCounts
+}
+
+type apiDigest struct {
+	Schema    string        `json:"schema"`
+	Digest    string        `json:"digest"`
+	Sample    string        `json:"sample"`
+	FirstSeen time.Time     `json:"firstSeen"`
+	LastSeen  time.Time     `json:"lastSeen"`
+	Total     apiCounts     `json:"total"` // since the monitor started
+	History   []apiInterval `json:"history"`
+}
+
+type apiAlert struct {
+	apiOffender
+	Since     time.Time `json:"since"`
+	LastFired time.Time `json:"lastFired"`
+	SilenceID string    `json:"silenceId,omitempty"` // set while a silence mutes the alert
+}
+
+type apiHealth struct {
+	LastSuccess   time.Time
+	LastFailure   time.Time
+	LastError     string
+	Failures      uint64
+	ConsecFailing int
+	LastTook      time.Duration
+}
+
+func newAPIState(cfg Config) *apiState {
+	return &apiState{
+		cfg:       cfg,
+		reporters: cfg.Reporters(),
+		started:   time.Now(),
+		history:   max(cfg.APIHistory(), 1),
+		digests:   make(map[snapKey]*apiDigest),
+		firing:    make(map[snapKey]*apiAlert),
+	}
+}
+
+// newAPIOffender converts one interval delta into its API form.
+func newAPIOffender(cfg Config, d digestStat, elapsed time.Duration) apiOffender {
+	o := apiOffender{
+		Schema: d.Schema,
+		Digest: d.Digest,
+		Sample: sampleText(d),
+		apiCounts: apiCounts{
+			Count:        d.CountStar,
+			RowsExamined: d.SumRowsExam,
+			RowsSent:     d.SumRowsSent,
+			RowsAffected: d.SumRowsAff,
+			BytesRead:    d.SumRowsExam * cfg.AvgRowRead(),
+			BytesWrite:   d.SumRowsSent * cfg.AvgRowSent(),
+		},
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		o.BytesPerSec = roundTo(float64(o.BytesRead+o.BytesWrite)/secs, 1)
+	}
+	return o
+}
+
+func (a *apiState) ObserveInterval(t tick) {
+	alerting := make(map[snapKey]bool, len(t.Alerts))
+	for _, o := range t.Alerts {
+		alerting[newSnapKey(o.Schema, o.Digest)] = true
+	}
+	silenced := make(map[snapKey]string, len(t.Silenced))
+	for _, sa := range t.Silenced {
+		silenced[newSnapKey(sa.Schema, sa.Digest)] = sa.SilenceID
+	}
+	latest := make([]apiOffender, 0, len(t.Delta))
+	for k, d := range t.Delta {
+		o := newAPIOffender(a.cfg, d, t.Elapsed)
+		o.Alerting = alerting[k]
+		_, o.Silenced = silenced[k]
+		latest = append(latest, o)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.latest, a.latestAt, a.elapsed = latest, t.At, t.Elapsed
+	a.intervals++
+	a.engine = t.Engine
+	a.health.LastSuccess, a.health.ConsecFailing, a.health.LastTook = t.At, 0, t.Took
+
+	for _, o := range latest {
+		k := newSnapKey(o.Schema, o.Digest)
+		d := a.digests[k]
+		if d == nil {
+			d = &apiDigest{Schema: o.Schema, Digest: o.Digest, FirstSeen: t.At}
+			a.digests[k] = d
+		}
+		if o.Sample != "" {
+			d.Sample = o.Sample
+		}
+		d.LastSeen = t.At
+		iv := apiInterval{At: t.At, apiCounts: o.apiCounts}
+		d.Total.add(iv.apiCounts)
+		d.History = append(d.History, iv)
+		if len(d.History) > a.history {
+			d.History = d.History[len(d.History)-a.history:]
+		}
+	}
+	// Forget digests idle for longer than the history window
+	if t.Elapsed > 0 {
+		idle := time.Duration(a.history) * t.Elapsed
+		for k, d := range a.digests {
+			if t.At.Sub(d.LastSeen) > idle {
+				delete(a.digests, k)
+			}
+		}
+	}
+
+	for _, o := range latest {
+		k := newSnapKey(o.Schema, o.Digest)
+		if !o.Alerting && !o.Silenced {
+			continue
+		}
+		al := a.firing[k]
+		if al == nil {
+			al = &apiAlert{Since: t.At}
+			a.firing[k] = al
+		}
+		al.apiOffender, al.LastFired, al.SilenceID = o, t.At, silenced[k]
+	}
+	for _, o := range t.Resolved {
+		delete(a.firing, newSnapKey(o.Schema, o.Digest))
+	}
+	// Muted alerts are never resolved by the monitor, so drop them once they stop
+	for k, al := range a.firing {
+		if al.Silenced && !al.LastFired.Equal(t.At) {
+			delete(a.firing, k)
+		}
+	}
+}
+
+func (a *apiState) SnapshotFailed(at time.Time, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.health.LastFailure = at
+	a.health.LastError = err.Error()
+	a.health.Failures++
+	a.health.ConsecFailing++
+}
+
+// register mounts the API routes and the health probes on mux.
+func (a *apiState) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/offenders", a.handleOffenders)
+	mux.HandleFunc("GET /api/v1/digests/{digest}", a.handleDigest)
+	mux.HandleFunc("GET /api/v1/alerts", a.handleAlerts)
+	mux.HandleFunc("GET /api/v1/status", a.handleStatus)
+	mux.HandleFunc("GET /healthz", a.handleHealthz)
+	mux.HandleFunc("GET /readyz", a.handleReadyz)
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSONError(w, http.StatusNotFound, "no such endpoint")
+	})
+}
+
+// apiSortKeys maps ?sort= values to offender orderings (largest first).
+var apiSortKeys = map[string]func(apiOffender) float64{
+	"bytes":    func(o apiOffender) float64 { return float64(o.BytesRead + o.BytesWrite) },
+	"read":     func(o apiOffender) float64 { return float64(o.BytesRead) },
+	"write":    func(o apiOffender) float64 { return float64(o.BytesWrite) },
+	"rate":     func(o apiOffender) float64 { return o.BytesPerSec },
+	"count":    func(o apiOffender) float64 { return float64(o.Count) },
+	"examined": func(o apiOffender) float64 { return float64(o.RowsExamined) },
+	"sent":     func(o apiOffender) float64 { return float64(o.RowsSent) },
+	"affected": func(o apiOffender) float64 { return float64(o.RowsAffected) },
+}
+
+// GET /api/v1/offenders?sort=bytes|read|write|rate|count|examined|sent|affected&order=desc|asc&limit=N&schema=S
+func (a *apiState) handleOffenders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sortBy := coalesce(q.Get("sort"), "bytes")
+	key, ok := apiSortKeys[sortBy]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "unknown sort "+strconv.Quote(sortBy))
+		return
+	}
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+	asc := strings.EqualFold(q.Get("order"), "asc")
+	schema := q.Get("schema")
+
+	a.mu.RLock()
+	out := make([]apiOffender, 0, len(a.latest))
+	for _, o := range a.latest {
+		if schema == "" || o.Schema == schema {
+			out = append(out, o)
+		}
+	}
+	at, elapsed := a.latestAt, a.elapsed
+	a.mu.RUnlock()
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if asc {
+			return key(out[i]) < key(out[j])
+		}
+		return key(out[i]) > key(out[j])
+	})
+	total := len(out)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"at":        apiTime(at),
+		"interval":  elapsed.String(),
+		"sort":      sortBy,
+		"total":     total,
+		"offenders": out,
+	})
+}
+
+// GET /api/v1/digests/{digest}[?schema=S]: one entry per schema the digest ran in.
+func (a *apiState) handleDigest(w http.ResponseWriter, r *http.Request) {
+	digest := r.PathValue("digest")
+	schema, bySchema := r.URL.Query().Get("schema"), r.URL.Query().Has("schema")
+	a.mu.RLock()
+	var out []apiDigest
+	for _, d := range a.digests {
+		if d.Digest != digest || (bySchema && d.Schema != schema) {
+			continue
+		}
+		c := *d
+		c.History = append([]apiInterval(nil), d.History...)
+		out = append(out, c)
+	}
+	a.mu.RUnlock()
+	if len(out) == 0 {
+		writeJSONError(w, http.StatusNotFound, "digest not seen recently")
+		return
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Schema < out[j].Schema })
+	writeJSON(w, http.StatusOK, map[string]any{"digest": digest, "schemas": out})
+}
+
+// GET /api/v1/alerts: currently firing and silenced alerts, longest-firing first.
+func (a *apiState) handleAlerts(w http.ResponseWriter, _ *http.Request) {
+	a.mu.RLock()
+	out := make([]apiAlert, 0, len(a.firing))
+	for _, al := range a.firing {
+		out = append(out, *al)
+	}
+	a.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Since.Before(out[j].Since) })
+	writeJSON(w, http.StatusOK, map[string]any{
+		"readThreshold":  a.cfg.ReadThreshold(),
+		"writeThreshold": a.cfg.WriteThreshold(),
+		"alerts":         out,
+	})
+}
+
+// GET /api/v1/status: configuration summary, snapshot timing and DB health.
+func (a *apiState) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	a.mu.RLock()
+	h := a.health
+	intervals, firing, digests := a.intervals, len(a.firing), len(a.digests)
+	var engine map[string]any
+	if a.engine != nil {
+		engine = map[string]any{"totalRead": a.engine.Total.Read, "totalWritten": a.engine.Total.Written, "deltaRead": a.engine.Delta.Read, "deltaWritten": a.engine.Delta.Written}
+	}
+	a.mu.RUnlock()
+
+	db := "unknown"
+	switch {
+	case h.ConsecFailing > 0:
+		db = "failing"
+	case !h.LastSuccess.IsZero():
+		db = "ok"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"target":    dsnTarget(a.cfg.DSN()),
+		"startedAt": a.started.UTC().Format(time.RFC3339),
+		"uptime":    time.Since(a.started).Round(time.Second).String(),
+		"config": map[string]any{
+			"interval":       a.cfg.Interval().String(),
+			"readThreshold":  a.cfg.ReadThreshold(),
+			"writeThreshold": a.cfg.WriteThreshold(),
+			"avgRowRead":     a.cfg.AvgRowRead(),
+			"avgRowSent":     a.cfg.AvgRowSent(),
+			"top":            a.cfg.TopN(),
+			"realIO":         a.cfg.RealIO(),
+			"redact":         a.cfg.RedactMode(),
+			"reporters":      a.reporters,
+		},
+		"snapshots": map[string]any{
+			"intervals":        intervals,
+			"lastSuccess":      apiTime(h.LastSuccess),
+			"lastDuration":     h.LastTook.String(),
+			"failures":         h.Failures,
+			"lastFailure":      apiTime(h.LastFailure),
+			"lastError":        h.LastError,
+			"trackedDigests":   digests,
+			"firingAlerts":     firing,
+			"consecutiveFails": h.ConsecFailing,
+		},
+		"db":     db,
+		"engine": engine,
+	})
+}
+
+// apiTime renders t as RFC 3339 in UTC, or null when unset.
+func apiTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
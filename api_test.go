@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAPIOffenders(t *testing.T) {
+	a := newAPIState(testConfig(func(c *config) { c.apiHistory = 5 }))
+	a.ObserveInterval(tick{
+		At:      time.Now(),
+		Elapsed: 10 * time.Second,
+		Delta: map[snapKey]digestStat{
+			newSnapKey("shop", "d1"): {Schema: "shop", Digest: "d1", CountStar: 5, SumRowsExam: 10},
+			newSnapKey("shop", "d2"): {Schema: "shop", Digest: "d2", CountStar: 9, SumRowsExam: 1000},
+			newSnapKey("crm", "d3"):  {Schema: "crm", Digest: "d3", CountStar: 1, SumRowsExam: 100},
+		},
+		Alerts: []offender{{Schema: "shop", Digest: "d2"}},
+	})
+	mux := http.NewServeMux()
+	a.register(mux)
+
+	for _, tc := range []struct {
+		query   string
+		status  int
+		digests []string
+		total   int
+	}{
+		{"", http.StatusOK, []string{"d2", "d3", "d1"}, 3},
+		{"sort=count", http.StatusOK, []string{"d2", "d1", "d3"}, 3},
+		{"sort=count&order=asc", http.StatusOK, []string{"d3", "d1", "d2"}, 3},
+		{"sort=count&limit=1", http.StatusOK, []string{"d2"}, 3},
+		{"sort=count&limit=0", http.StatusOK, []string{"d2", "d1", "d3"}, 3},
+		{"schema=shop&sort=examined", http.StatusOK, []string{"d2", "d1"}, 2},
+		{"schema=none", http.StatusOK, nil, 0},
+		{"sort=latency", http.StatusBadRequest, nil, 0},
+		{"limit=-1", http.StatusBadRequest, nil, 0},
+		{"limit=ten", http.StatusBadRequest, nil, 0},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/offenders?"+tc.query, nil))
+		if rec.Code != tc.status {
+			t.Errorf("?%s: status %d, want %d: %s", tc.query, rec.Code, tc.status, rec.Body)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		var body struct {
+			Total     int
+			Offenders []apiOffender
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("?%s: %v", tc.query, err)
+		}
+		var digests []string
+		for _, o := range body.Offenders {
+			digests = append(digests, o.Digest)
+			if o.Alerting != (o.Digest == "d2") {
+				t.Errorf("?%s: %s alerting = %v", tc.query, o.Digest, o.Alerting)
+			}
+		}
+		if !reflect.DeepEqual(digests, tc.digests) || body.Total != tc.total {
+			t.Errorf("?%s: offenders %v (total %d), want %v (total %d)", tc.query, digests, body.Total, tc.digests, tc.total)
+		}
+	}
+}
+
+func TestAPIUnknownEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	newAPIState(testConfig(nil)).register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d %q, want a JSON 404", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
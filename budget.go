@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// budgetTracker accumulates the estimated bytes each schema reads/writes per
+// calendar hour or day and warns when configured burn percentages are crossed.
+// Accumulators are persisted so a restart does not reset the current window.
+//
+// Budget spec (MON_BUDGETS), rules separated by ';':
+//
+//	billing=read:50GB/day,write:5GB/day;tenant_*=read:10GB/hour;sum:tenant_*=read:200GB/day
+//
+// The left side is a schema glob (path.Match syntax); each concrete schema
+// matching a glob gets its own accumulator, or with the sum: prefix all
+// matching schemas share one. Metrics: read (rows examined × AvgRowRead) and
+// write (rows sent × AvgRowSent). Periods: hour, day.
+type budgetTracker struct {
+	log        *slog.Logger
+	rules      []budgetRule
+	warnAt     []int
+	statePath  string
+	avgRowRead uint64
+	avgRowSent uint64
+
+	acc     map[string]*budgetAcc
+	dirty   bool
+	savedAt time.Time
+}
+
+type budgetRule struct {
+	Pattern   string
+	Aggregate bool   // one accumulator for all matching schemas
+	Metric    string // read|write
+	Limit     uint64
+	Period    string // hour|day
+}
+
+// name is the rule's schema side as written in the spec.
+func (r budgetRule) name() string {
+	if r.Aggregate {
+		return budgetSumPrefix + r.Pattern
+	}
+	return r.Pattern
+}
+
+// budgetSumPrefix marks a rule that sums every matching schema.
+const budgetSumPrefix = "sum:"
+
+// budgetAcc is the persisted usage of one rule for one schema in the current
+// window. For a sum: rule, Schema is the glob and Used covers every match.
+type budgetAcc struct {
+	Pattern     string    `json:"pattern"`
+	Schema      string    `json:"schema"`
+	Metric      string    `json:"metric"`
+	Period      string    `json:"period"`
+	Limit       uint64    `json:"limit"`
+	WindowStart time.Time `json:"windowStart"`
+	Used        uint64    `json:"used"`
+	Fired       []int     `json:"fired,omitempty"`
+}
+
+type budgetState struct {
+	Version      int          `json:"version"`
+	Accumulators []*budgetAcc `json:"accumulators"`
+}
+
+const budgetSaveInterval = time.Minute
+
+// parseBudgets parses the MON_BUDGETS spec.
+func parseBudgets(spec string) ([]budgetRule, error) {
+	var rules []budgetRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, limits, ok := strings.Cut(entry, "=")
+		pattern = strings.TrimSpace(pattern)
+		pattern, aggregate := strings.CutPrefix(pattern, budgetSumPrefix)
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("budget %q: want [sum:]<schema>=<metric>:<limit>/<period>", entry)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("budget %q: bad schema pattern: %w", entry, err)
+		}
+		for _, l := range splitList(limits) {
+			metric, rest, ok1 := strings.Cut(l, ":")
+			limitStr, period, ok2 := strings.Cut(rest, "/")
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("budget %q: want <metric>:<limit>/<period>, got %q", entry, l)
+			}
+			metric = strings.ToLower(strings.TrimSpace(metric))
+			if metric != "read" && metric != "write" {
+				return nil, fmt.Errorf("budget %q: unknown metric %q (want read|write)", entry, metric)
+			}
+			switch strings.ToLower(strings.TrimSpace(period)) {
+			case "h", "hour", "hourly":
+				period = "hour"
+			case "d", "day", "daily":
+				period = "day"
+			default:
+				return nil, fmt.Errorf("budget %q: unknown period %q (want hour|day)", entry, period)
+			}
+			limit, err := parseBytesFlag(limitStr)
+			if err != nil || limit == 0 {
+				return nil, fmt.Errorf("budget %q: invalid limit %q", entry, limitStr)
+			}
+			rules = append(rules, budgetRule{Pattern: pattern, Aggregate: aggregate, Metric: metric, Limit: limit, Period: period})
+		}
+	}
+	return rules, nil
+}
+
+// newBudgetTracker parses the configured budgets and restores persisted
+// accumulators that still belong to the current window.
+func newBudgetTracker(cfg Config, log *slog.Logger) (*budgetTracker, error) {
+	rules, err := parseBudgets(cfg.Budgets())
+	if err != nil {
+		return nil, err
+	}
+	warnAt := append([]int(nil), cfg.BudgetWarnAt()...)
+	sort.Ints(warnAt)
+	b := &budgetTracker{
+		log:        log,
+		rules:      rules,
+		warnAt:     warnAt,
+		statePath:  cfg.BudgetStateFile(),
+		avgRowRead: cfg.AvgRowRead(),
+		avgRowSent: cfg.AvgRowSent(),
+		acc:        make(map[string]*budgetAcc),
+	}
+	if b.statePath == "" {
+		return b, nil
+	}
+	data, err := os.ReadFile(b.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st budgetState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", b.statePath, err)
+	}
+	now := time.Now()
+	for _, a := range st.Accumulators {
+		if !a.WindowStart.Equal(budgetWindowStart(a.Period, now)) {
+			continue
+		}
+		b.acc[budgetAccKey(a.Pattern, a.Schema, a.Metric, a.Period)] = a
+	}
+	log.Info("budget state restored", "path", b.statePath, "accumulators", len(b.acc))
+	return b, nil
+}
+
+// budgetWindowStart returns the start of the calendar hour/day containing t (local time).
+func budgetWindowStart(period string, t time.Time) time.Time {
+	if period == "hour" {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func budgetAccKey(pattern, schema, metric, period string) string {
+	return pattern + "|" + schema + "|" + metric + "|" + period
+}
+
+func (b *budgetTracker) ObserveInterval(t tick) {
+	// Per-schema totals for this interval
+	type rw struct{ read, write uint64 }
+	perSchema := make(map[string]rw)
+	for _, d := range t.Delta {
+		v := perSchema[d.Schema]
+		v.read += d.SumRowsExam * b.avgRowRead
+		v.write += d.SumRowsSent * b.avgRowSent
+		perSchema[d.Schema] = v
+	}
+
+	// Close windows that have ended
+	for key, a := range b.acc {
+		if start := budgetWindowStart(a.Period, t.At); !a.WindowStart.Equal(start) {
+			b.log.Info("budget window closed",
+				"schema", a.Schema,
+				"rule", a.Pattern,
+				"metric", a.Metric,
+				"period", a.Period,
+				"windowStart", a.WindowStart.Format(time.RFC3339),
+				"used", bytesToHuman(a.Used),
+				"limit", bytesToHuman(a.Limit),
+				"pct", roundTo(budgetPct(a), 1),
+			)
+			delete(b.acc, key)
+			b.dirty = true
+		}
+	}
+
+	for _, r := range b.rules {
+		start := budgetWindowStart(r.Period, t.At)
+		usage := make(map[string]uint64) // accumulator schema -> bytes this interval
+		for schema, v := range perSchema {
+			if ok, _ := path.Match(r.Pattern, schema); !ok {
+				continue
+			}
+			used := v.read
+			if r.Metric == "write" {
+				used = v.write
+			}
+			if r.Aggregate {
+				usage[r.Pattern] += used
+			} else {
+				usage[schema] += used
+			}
+		}
+		for schema, used := range usage {
+			if used == 0 {
+				continue
+			}
+			key := budgetAccKey(r.name(), schema, r.Metric, r.Period)
+			a := b.acc[key]
+			if a == nil {
+				a = &budgetAcc{Pattern: r.name(), Schema: schema, Metric: r.Metric, Period: r.Period, Limit: r.Limit, WindowStart: start}
+				b.acc[key] = a
+			}
+			a.Used += used
+			a.Limit = r.Limit
+			b.dirty = true
+			b.checkBurn(a)
+		}
+	}
+
+	if b.statePath != "" && b.dirty && t.At.Sub(b.savedAt) >= budgetSaveInterval {
+		if err := b.save(); err != nil {
+			b.log.Error("budget state save", "path", b.statePath, "err", err)
+		}
+		b.savedAt = t.At
+	}
+}
+
+// checkBurn emits one warning per configured percentage crossed in the current window.
+func (b *budgetTracker) checkBurn(a *budgetAcc) {
+	pct := budgetPct(a)
+	for _, level := range b.warnAt {
+		if pct < float64(level) || containsInt(a.Fired, level) {
+			continue
+		}
+		a.Fired = append(a.Fired, level)
+		b.log.Warn("budget burn",
+			"schema", a.Schema,
+			"rule", a.Pattern,
+			"metric", a.Metric,
+			"period", a.Period,
+			"level", level,
+			"pct", roundTo(pct, 1),
+			"used", bytesToHuman(a.Used),
+			"limit", bytesToHuman(a.Limit),
+			"exceeded", pct >= 100,
+			"windowStart", a.WindowStart.Format(time.RFC3339),
+		)
+	}
+}
+
+func budgetPct(a *budgetAcc) float64 {
+	if a.Limit == 0 {
+		return 0
+	}
+	return float64(a.Used) * 100 / float64(a.Limit)
+}
+
+// Close flushes the accumulators to disk.
+func (b *budgetTracker) Close() error {
+	if b.statePath == "" || !b.dirty {
+		return nil
+	}
+	return b.save()
+}
+
+func (b *budgetTracker) save() error {
+	st := budgetState{Version: 1, Accumulators: make([]*budgetAcc, 0, len(b.acc))}
+	for _, a := range b.acc {
+		st.Accumulators = append(st.Accumulators, a)
+	}
+	sort.Slice(st.Accumulators, func(i, j int) bool {
+		x, y := st.Accumulators[i], st.Accumulators[j]
+		return budgetAccKey(x.Pattern, x.Schema, x.Metric, x.Period) < budgetAccKey(y.Pattern, y.Schema, y.Metric, y.Period)
+	})
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(b.statePath, data); err != nil {
+		return err
+	}
+	b.dirty = false
+	return nil
+}
+
+func containsInt(xs []int, v int) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBudgets(t *testing.T) {
+	rules, err := parseBudgets("billing=read:50GB/day,write:5GB/day; sum: tenant_*=read:1KB/hour")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []budgetRule{
+		{Pattern: "billing", Metric: "read", Limit: 50 << 30, Period: "day"},
+		{Pattern: "billing", Metric: "write", Limit: 5 << 30, Period: "day"},
+		{Pattern: "tenant_*", Aggregate: true, Metric: "read", Limit: 1 << 10, Period: "hour"},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d: %+v", len(rules), len(want), rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+	for _, bad := range []string{"sum:=read:1GB/day", "x=read:1GB/week", "x=cpu:1GB/day", "x[=read:1GB/day"} {
+		if _, err := parseBudgets(bad); err == nil {
+			t.Errorf("parseBudgets(%q) accepted", bad)
+		}
+	}
+}
+
+func TestBudgetSumRuleSharesOneAccumulator(t *testing.T) {
+	b, err := newBudgetTracker(&config{budgets: "tenant_*=read:1000B/hour;sum:tenant_*=read:1000B/hour", budgetWarnAt: []int{100},
+		avgRowRead: 1, avgRowSent: 1}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	b.ObserveInterval(tick{At: at, Delta: map[snapKey]digestStat{
+		newSnapKey("tenant_a", "d1"): {Schema: "tenant_a", Digest: "d1", SumRowsExam: 400},
+		newSnapKey("tenant_b", "d1"): {Schema: "tenant_b", Digest: "d1", SumRowsExam: 700},
+		newSnapKey("billing", "d1"):  {Schema: "billing", Digest: "d1", SumRowsExam: 5000},
+	}})
+
+	sum := b.acc[budgetAccKey("sum:tenant_*", "tenant_*", "read", "hour")]
+	if sum == nil || sum.Used != 1100 {
+		t.Fatalf("sum accumulator = %+v, want 1100 bytes used", sum)
+	}
+	if len(sum.Fired) != 1 {
+		t.Errorf("sum over its limit fired %v, want the 100%% level", sum.Fired)
+	}
+	for schema, want := range map[string]uint64{"tenant_a": 400, "tenant_b": 700} {
+		a := b.acc[budgetAccKey("tenant_*", schema, "read", "hour")]
+		if a == nil || a.Used != want || len(a.Fired) != 0 {
+			t.Errorf("%s accumulator = %+v, want %d bytes and no burn", schema, a, want)
+		}
+	}
+	if len(b.acc) != 3 {
+		t.Errorf("got %d accumulators, want 3 (billing matches no rule)", len(b.acc))
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// digestCatalog remembers every query shape (schema + digest) the monitor has
+// seen and persists it to a local JSON file, so that after a deploy the first
+// execution of a brand-new shape is reported as "new query shape", and shapes
+// that stop running are reported as "query shape disappeared".
+type digestCatalog struct {
+	log            *slog.Logger
+	path           string
+	disappearAfter time.Duration
+	saveInterval   time.Duration
+	avgRowRead     uint64
+	avgRowSent     uint64
+
+	entries map[snapKey]*catalogEntry
+	dirty   bool
+	savedAt time.Time
+}
+
+// catalogEntry is the persisted state of one query shape.
+type catalogEntry struct {
+	Schema       string    `json:"schema"`
+	Digest       string    `json:"digest"`
+	Text         string    `json:"text"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+	Count        uint64    `json:"count"`
+	RowsExamined uint64    `json:"rowsExamined"`
+	RowsSent     uint64    `json:"rowsSent"`
+	RowsAffected uint64    `json:"rowsAffected"`
+	Gone         bool      `json:"gone,omitempty"`
+}
+
+// catalogFile is the on-disk layout.
+type catalogFile struct {
+	Version int             `json:"version"`
+	Entries []*catalogEntry `json:"entries"`
+}
+
+// loadDigestCatalog reads the catalog from cfg.CatalogFile(). A missing file is
+// not an error: the catalog starts empty and is seeded from the first snapshot.
+func loadDigestCatalog(cfg Config, log *slog.Logger) (*digestCatalog, error) {
+	c := &digestCatalog{
+		log:            log,
+		path:           cfg.CatalogFile(),
+		disappearAfter: cfg.CatalogDisappearAfter(),
+		saveInterval:   cfg.CatalogSaveInterval(),
+		avgRowRead:     cfg.AvgRowRead(),
+		avgRowSent:     cfg.AvgRowSent(),
+		entries:        make(map[snapKey]*catalogEntry),
+	}
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f catalogFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", c.path, err)
+	}
+	for _, e := range f.Entries {
+		c.entries[newSnapKey(e.Schema, e.Digest)] = e
+	}
+	log.Info("digest catalog loaded", "path", c.path, "entries", len(c.entries))
+	return c, nil
+}
+
+// ObserveBaseline records shapes present at startup. On the very first run the
+// catalog is seeded silently; afterwards any shape not yet known has appeared
+// while the monitor was down and is reported as new.
+func (c *digestCatalog) ObserveBaseline(at time.Time, snap snapshot) {
+	if len(c.entries) == 0 {
+		for k, d := range snap {
+			c.entries[k] = &catalogEntry{Schema: d.Schema, Digest: d.Digest, Text: sampleText(d), FirstSeen: at, LastSeen: at}
+		}
+		c.dirty = len(snap) > 0
+		c.log.Info("digest catalog seeded", "path", c.path, "entries", len(snap))
+		return
+	}
+	for k, d := range snap {
+		if _, ok := c.entries[k]; ok {
+			continue
+		}
+		e := &catalogEntry{Schema: d.Schema, Digest: d.Digest, Text: sampleText(d), FirstSeen: at, LastSeen: at}
+		c.entries[k] = e
+		c.dirty = true
+		c.log.Warn("new query shape",
+			"digest", d.Digest,
+			"schema", d.Schema,
+			"source", "startup",
+			"sample", e.Text,
+		)
+	}
+}
+
+func (c *digestCatalog) ObserveInterval(t tick) {
+	for k, d := range t.Delta {
+		if d.CountStar == 0 {
+			continue
+		}
+		e, known := c.entries[k]
+		if !known {
+			e = &catalogEntry{Schema: d.Schema, Digest: d.Digest, FirstSeen: t.At}
+			c.entries[k] = e
+		}
+		e.Text = sampleText(d)
+		e.LastSeen = t.At
+		e.Count += d.CountStar
+		e.RowsExamined += d.SumRowsExam
+		e.RowsSent += d.SumRowsSent
+		e.RowsAffected += d.SumRowsAff
+		c.dirty = true
+		switch {
+		case !known:
+			c.log.Warn("new query shape",
+				"digest", d.Digest,
+				"schema", d.Schema,
+				"count", d.CountStar,
+				"rowsExamined", d.SumRowsExam,
+				"rowsSent", d.SumRowsSent,
+				"rowsAffected", d.SumRowsAff,
+				"bytesRead", bytesToHuman(d.SumRowsExam*c.avgRowRead),
+				"bytesWrite", bytesToHuman(d.SumRowsSent*c.avgRowSent),
+				"sample", e.Text,
+			)
+		case e.Gone:
+			e.Gone = false
+			c.log.Info("query shape reappeared", "digest", d.Digest, "schema", d.Schema, "count", d.CountStar, "sample", e.Text)
+		}
+	}
+
+	if c.disappearAfter > 0 {
+		for _, e := range c.entries {
+			if e.Gone || t.At.Sub(e.LastSeen) < c.disappearAfter {
+				continue
+			}
+			e.Gone = true
+			c.dirty = true
+			c.log.Info("query shape disappeared",
+				"digest", e.Digest,
+				"schema", e.Schema,
+				"lastSeen", e.LastSeen.Format(time.RFC3339),
+				"unseenFor", t.At.Sub(e.LastSeen).Round(time.Second).String(),
+				"totalCount", e.Count,
+				"sample", e.Text,
+			)
+		}
+	}
+
+	if c.dirty && t.At.Sub(c.savedAt) >= c.saveInterval {
+		if err := c.save(); err != nil {
+			c.log.Error("digest catalog save", "path", c.path, "err", err)
+		}
+		c.savedAt = t.At
+	}
+}
+
+// Close flushes pending changes to disk.
+func (c *digestCatalog) Close() error {
+	if !c.dirty {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *digestCatalog) save() error {
+	f := catalogFile{Version: 1, Entries: make([]*catalogEntry, 0, len(c.entries))}
+	for _, e := range c.entries {
+		f.Entries = append(f.Entries, e)
+	}
+	sort.Slice(f.Entries, func(i, j int) bool { return f.Entries[i].FirstSeen.Before(f.Entries[j].FirstSeen) })
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.path, data); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -36,6 +38,107 @@ type Config interface {
 	LogMaxAgeDays() int       // remove old files after N days
 	LogCompress() bool        // gzip old files
 	LogLevel() string         // INFO|WARN|ERROR|DEBUG
+	// Redaction of SQL text
+	RedactMode() string       // off|mask|digest
+	RedactPatterns() []string // extra regexes replaced with [REDACTED]
+	// Efficiency analysis
+	EfficiencyWindow() time.Duration // report window for inefficient queries (0 = disabled)
+	EfficiencyRatio() float64        // min rows examined per row returned to flag
+	EfficiencyMinExec() uint64       // min executions in the window to flag
+	EfficiencyTopN() int             // max digests per report
+	// Digest catalog
+	CatalogFile() string                  // path of the persisted digest catalog (empty = disabled)
+	CatalogDisappearAfter() time.Duration // report a digest as gone after this long unseen
+	CatalogSaveInterval() time.Duration   // how often a changed catalog is written
+	// Ignore rules
+	IgnoreDigests() []string // digests never ranked or alerted
+	IgnoreSchemas() []string // schemas to ignore (e.g. performance_schema,mysql,sys)
+	IgnoreUsers() []string   // users whose statements are ignored (e.g. backup, repl)
+	IgnoreText() []string    // DIGEST_TEXT regexes to ignore
+	IgnoreSelf() bool        // exclude the monitor's own statements
+	// Throughput budgets
+	Budgets() string         // [sum:]schema=metric:limit/period,...;... (empty = disabled)
+	BudgetWarnAt() []int     // burn percentages that emit a warning
+	BudgetStateFile() string // where accumulators are persisted (empty = memory only)
+	// Reporters
+	Reporters() []string            // enabled reporters, e.g. log,webhook
+	ReporterQueue() int             // per-sink queue size when fanning out
+	ReporterTimeout() time.Duration // per-call timeout for each sink
+	// Webhook reporter
+	WebhookURLs() []string         // endpoints alerts are POSTed to
+	WebhookTemplate() string       // json|slack|teams or path to a text/template file
+	WebhookContentType() string    // Content-Type of the payload
+	WebhookSecret() string         // HMAC-SHA256 signing key (empty = unsigned)
+	WebhookTimeout() time.Duration // per-request timeout
+	WebhookRetries() int           // retries with exponential backoff
+	WebhookSpoolDir() string       // on-disk spool for undelivered payloads (empty = disabled)
+	WebhookSpoolMax() int          // max spooled payloads, oldest dropped first
+	// Metrics
+	MetricsTopDigests() int // per-digest series exported at /metrics (cardinality cap)
+	MetricsDigestIdle() int // intervals without activity before a digest's counters are dropped
+	// Alert lifecycle
+	AlertResolveAfter() int // clear intervals before a firing alert is resolved
+	// Alertmanager reporter
+	AlertmanagerURL() string               // Alertmanager base URL, e.g. http://alertmanager:9093
+	AlertmanagerResend() time.Duration     // how often firing alerts are re-sent
+	AlertmanagerSeverity() string          // severity label
+	AlertmanagerLabels() map[string]string // extra static labels (k=v,...)
+	AlertmanagerTimeout() time.Duration    // per-request timeout
+	// OTLP export
+	OTLPEndpoint() string             // OTLP/HTTP base URL, e.g. http://otel-collector:4318 (empty = disabled)
+	OTLPHeaders() map[string]string   // extra request headers (k=v,...)
+	OTLPServiceName() string          // service.name resource attribute
+	OTLPBatchSize() int               // data points + log records that trigger an early flush
+	OTLPFlushInterval() time.Duration // max time data is buffered
+	OTLPRetries() int                 // retries on network errors, 429 and 5xx
+	OTLPTimeout() time.Duration       // per-request timeout
+	// Loki push
+	LokiURL() string                     // Loki base URL, e.g. http://loki:3100 (empty = disabled)
+	LokiTenant() string                  // X-Scope-OrgID tenant header
+	LokiLabels() []string                // log fields promoted to stream labels
+	LokiStaticLabels() map[string]string // constant stream labels (k=v,...)
+	LokiBatchBytes() int                 // flush once this many bytes are buffered
+	LokiBatchWait() time.Duration        // max time a line is buffered
+	LokiGzip() bool                      // gzip request bodies
+	LokiRetries() int                    // retries on network errors, 429 and 5xx
+	LokiTimeout() time.Duration          // per-request timeout
+	// StatsD
+	StatsdAddr() string              // agent host:port, e.g. 127.0.0.1:8125 (empty = disabled)
+	StatsdFormat() string            // statsd|dogstatsd
+	StatsdPrefix() string            // metric name prefix
+	StatsdTags() map[string]string   // static DogStatsD tags (k=v,...)
+	StatsdTopDigests() int           // digests emitted per interval
+	StatsdDigestSampleRate() float64 // sample rate for digest metrics (0..1]
+	StatsdMTU() int                  // max packet size in bytes
+	// Summary reports
+	SummaryPeriods() []string // hour|day|week, comma-separated (empty = disabled)
+	SummaryDir() string       // directory for reports and the open-period state
+	SummaryFormats() []string // md|html|csv
+	SummaryTop() int          // top digests listed
+	SummaryNotify() bool      // hand finished reports to reporters that accept them
+	// HTTP server
+	HTTPAddr() string           // host:port, unix:/path/to.sock, or off
+	HTTPTLSCert() string        // PEM certificate; enables TLS together with the key
+	HTTPTLSKey() string         // PEM private key
+	HTTPBasicAuth() []string    // user:password entries
+	HTTPBearerTokens() []string // accepted bearer tokens
+	HTTPAuthExemptHealth() bool // serve /healthz and /readyz without credentials
+	HTTPAnonymousWrites() bool  // allow PATCH/POST/DELETE APIs while HTTP auth is off
+	// JSON API
+	APIHistory() int // intervals of history kept per digest
+	// Readiness
+	ReadyMaxIntervals() int // /readyz fails when the last successful snapshot is older than N intervals
+	// Runtime configuration
+	ConfigFile() string // KEY=VALUE file of MON_* settings re-read on SIGHUP (empty = environment only)
+	// Silences
+	SilenceFile() string                // where silences are persisted (empty = memory only)
+	SilenceSaveInterval() time.Duration // how often changed suppression counters are written
+	// SSE streaming
+	SSEMaxClients() int            // concurrent /logs and /events clients (0 = unlimited)
+	SSEBuffer() int                // per-client queue, in messages
+	SSESlowTimeout() time.Duration // disconnect clients still losing messages after this (0 = never)
+	SSELogRing() int               // log lines kept for /logs replay
+	SSEEventRing() int             // events kept for /events replay
 
 	// Setters
 	SetDSN(string)
@@ -58,6 +161,87 @@ type Config interface {
 	SetLogMaxAgeDays(int)
 	SetLogCompress(bool)
 	SetLogLevel(string)
+	SetRedactMode(string)
+	SetRedactPatterns([]string)
+	SetEfficiencyWindow(time.Duration)
+	SetEfficiencyRatio(float64)
+	SetEfficiencyMinExec(uint64)
+	SetEfficiencyTopN(int)
+	SetCatalogFile(string)
+	SetCatalogDisappearAfter(time.Duration)
+	SetCatalogSaveInterval(time.Duration)
+	SetIgnoreDigests([]string)
+	SetIgnoreSchemas([]string)
+	SetIgnoreUsers([]string)
+	SetIgnoreText([]string)
+	SetIgnoreSelf(bool)
+	SetBudgets(string)
+	SetBudgetWarnAt([]int)
+	SetBudgetStateFile(string)
+	SetReporters([]string)
+	SetReporterQueue(int)
+	SetReporterTimeout(time.Duration)
+	SetWebhookURLs([]string)
+	SetWebhookTemplate(string)
+	SetWebhookContentType(string)
+	SetWebhookSecret(string)
+	SetWebhookTimeout(time.Duration)
+	SetWebhookRetries(int)
+	SetWebhookSpoolDir(string)
+	SetWebhookSpoolMax(int)
+	SetMetricsTopDigests(int)
+	SetMetricsDigestIdle(int)
+	SetAlertResolveAfter(int)
+	SetAlertmanagerURL(string)
+	SetAlertmanagerResend(time.Duration)
+	SetAlertmanagerSeverity(string)
+	SetAlertmanagerLabels(map[string]string)
+	SetAlertmanagerTimeout(time.Duration)
+	SetOTLPEndpoint(string)
+	SetOTLPHeaders(map[string]string)
+	SetOTLPServiceName(string)
+	SetOTLPBatchSize(int)
+	SetOTLPFlushInterval(time.Duration)
+	SetOTLPRetries(int)
+	SetOTLPTimeout(time.Duration)
+	SetLokiURL(string)
+	SetLokiTenant(string)
+	SetLokiLabels([]string)
+	SetLokiStaticLabels(map[string]string)
+	SetLokiBatchBytes(int)
+	SetLokiBatchWait(time.Duration)
+	SetLokiGzip(bool)
+	SetLokiRetries(int)
+	SetLokiTimeout(time.Duration)
+	SetStatsdAddr(string)
+	SetStatsdFormat(string)
+	SetStatsdPrefix(string)
+	SetStatsdTags(map[string]string)
+	SetStatsdTopDigests(int)
+	SetStatsdDigestSampleRate(float64)
+	SetStatsdMTU(int)
+	SetSummaryPeriods([]string)
+	SetSummaryDir(string)
+	SetSummaryFormats([]string)
+	SetSummaryTop(int)
+	SetSummaryNotify(bool)
+	SetHTTPAddr(string)
+	SetHTTPTLSCert(string)
+	SetHTTPTLSKey(string)
+	SetHTTPBasicAuth([]string)
+	SetHTTPBearerTokens([]string)
+	SetHTTPAuthExemptHealth(bool)
+	SetHTTPAnonymousWrites(bool)
+	SetAPIHistory(int)
+	SetReadyMaxIntervals(int)
+	SetConfigFile(string)
+	SetSilenceFile(string)
+	SetSilenceSaveInterval(time.Duration)
+	SetSSEMaxClients(int)
+	SetSSEBuffer(int)
+	SetSSESlowTimeout(time.Duration)
+	SetSSELogRing(int)
+	SetSSEEventRing(int)
 }
 
 const (
@@ -71,10 +255,14 @@ const (
 	defaultAvgRowSent        = 200 // bytes per row sent
 	defaultTopN              = 5
 	defaultRealIO            = true // enable engine I/O bytes monitoring by default
+	defaultRedactMode        = "mask"
 )
 
-// config is the hidden implementation of Config.
+// config is the hidden implementation of Config. Getters and setters are safe
+// for concurrent use, so settings can change at runtime (see configManager).
 type config struct {
+	mu sync.RWMutex
+
 	dsn            string
 	interval       time.Duration
 	readThreshold  uint64
@@ -98,6 +286,107 @@ type config struct {
 	logMaxAgeDays int
 	logCompress   bool
 	logLevel      string
+	// Redaction
+	redactMode     string
+	redactPatterns []string
+	// Efficiency analysis
+	efficiencyWindow  time.Duration
+	efficiencyRatio   float64
+	efficiencyMinExec uint64
+	efficiencyTopN    int
+	// Digest catalog
+	catalogFile           string
+	catalogDisappearAfter time.Duration
+	catalogSaveInterval   time.Duration
+	// Ignore rules
+	ignoreDigests []string
+	ignoreSchemas []string
+	ignoreUsers   []string
+	ignoreText    []string
+	ignoreSelf    bool
+	// Throughput budgets
+	budgets         string
+	budgetWarnAt    []int
+	budgetStateFile string
+	// Reporters
+	reporters       []string
+	reporterQueue   int
+	reporterTimeout time.Duration
+	// Webhook reporter
+	webhookURLs        []string
+	webhookTemplate    string
+	webhookContentType string
+	webhookSecret      string
+	webhookTimeout     time.Duration
+	webhookRetries     int
+	webhookSpoolDir    string
+	webhookSpoolMax    int
+	// Metrics
+	metricsTopDigests int
+	metricsDigestIdle int
+	// Alert lifecycle
+	alertResolveAfter int
+	// Alertmanager reporter
+	alertmanagerURL      string
+	alertmanagerResend   time.Duration
+	alertmanagerSeverity string
+	alertmanagerLabels   map[string]string
+	alertmanagerTimeout  time.Duration
+	// OTLP export
+	otlpEndpoint      string
+	otlpHeaders       map[string]string
+	otlpServiceName   string
+	otlpBatchSize     int
+	otlpFlushInterval time.Duration
+	otlpRetries       int
+	otlpTimeout       time.Duration
+	// Loki push
+	lokiURL          string
+	lokiTenant       string
+	lokiLabels       []string
+	lokiStaticLabels map[string]string
+	lokiBatchBytes   int
+	lokiBatchWait    time.Duration
+	lokiGzip         bool
+	lokiRetries      int
+	lokiTimeout      time.Duration
+	// StatsD
+	statsdAddr             string
+	statsdFormat           string
+	statsdPrefix           string
+	statsdTags             map[string]string
+	statsdTopDigests       int
+	statsdDigestSampleRate float64
+	statsdMTU              int
+	// Summary reports
+	summaryPeriods []string
+	summaryDir     string
+	summaryFormats []string
+	summaryTop     int
+	summaryNotify  bool
+	// HTTP server
+	httpAddr             string
+	httpTLSCert          string
+	httpTLSKey           string
+	httpBasicAuth        []string
+	httpBearerTokens     []string
+	httpAuthExemptHealth bool
+	httpAnonymousWrites  bool
+	// JSON API
+	apiHistory int
+	// Readiness
+	readyMaxIntervals int
+	// Runtime configuration
+	configFile string
+	// Silences
+	silenceFile         string
+	silenceSaveInterval time.Duration
+	// SSE streaming
+	sseMaxClients  int
+	sseBuffer      int
+	sseSlowTimeout time.Duration
+	sseLogRing     int
+	sseEventRing   int
 }
 
 // LoadConfig parses flags and env vars, preserving precedence: flags > env > defaults
@@ -119,6 +408,7 @@ func LoadConfig() Config {
 		minPrintRowsStr string
 		// Simple mode toggle
 		simpleMode        bool
+		redactMode        string
 )
 
 	// If flags are not yet defined/parsed, define them. Otherwise, read from existing FlagSet.
@@ -167,6 +457,9 @@ func LoadConfig() Config {
 		if flag.Lookup("min-print-rows") == nil {
 			flag.StringVar(&minPrintRowsStr, "min-print-rows", "", "minimum rows to print offenders (0 = disabled)")
 		}
+		if flag.Lookup("redact") == nil {
+			flag.StringVar(&redactMode, "redact", defaultRedactMode, "redaction of SQL samples before logging: mask (replace literals, default)|digest (digest only)|off")
+		}
 		flag.Parse()
 	} else {
 		// Read from existing parsed flags if present
@@ -184,6 +477,7 @@ func LoadConfig() Config {
 		if f := flag.Lookup("read-rows-threshold"); f != nil { readRowsThrStr = f.Value.String() }
 		if f := flag.Lookup("write-rows-threshold"); f != nil { writeRowsThrStr = f.Value.String() }
 		if f := flag.Lookup("min-print-rows"); f != nil { minPrintRowsStr = f.Value.String() }
+		if f := flag.Lookup("redact"); f != nil { redactMode = f.Value.String() }
 	}
 
 	setFlags := map[string]bool{}
@@ -255,6 +549,25 @@ func LoadConfig() Config {
 		}
 	}
 
+	if !setFlags["redact"] {
+		if v := os.Getenv("MON_REDACT"); v != "" {
+			redactMode = v
+		}
+	}
+	redactMode = strings.ToLower(strings.TrimSpace(redactMode))
+	switch redactMode {
+	case "":
+		redactMode = defaultRedactMode
+	case "off", "mask", "digest":
+	default:
+		log.Fatalf("invalid redact mode %q (want off|mask|digest)", redactMode)
+	}
+
+	logMode, err := parseLogMode(os.Getenv("MON_LOG_MODE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	if dsn == "" {
 		log.Fatal("dsn is required. Example: -dsn \"user:pass@tcp(127.0.0.1:3306)/\" or set MON_DSN env var")
 	}
@@ -355,61 +668,344 @@ func LoadConfig() Config {
 		writeRowsThreshold:  writeRowsThr,
 		minPrintRows:        minPrintRows,
 		simple:              simpleMode,
-		logMode:             coalesce(os.Getenv("MON_LOG_MODE"), "stdout"),
+		logMode:             logMode,
 		logFile:             coalesce(os.Getenv("MON_LOG_FILE"), "/logs/monitor.jsonl"),
 		logMaxSizeMB:        atoiDefault(os.Getenv("MON_LOG_MAX_SIZE_MB"), 50),
 		logMaxBackups:       atoiDefault(os.Getenv("MON_LOG_MAX_BACKUPS"), 7),
 		logMaxAgeDays:       atoiDefault(os.Getenv("MON_LOG_MAX_AGE_DAYS"), 14),
 		logCompress:         boolEnv(os.Getenv("MON_LOG_COMPRESS"), true),
 		logLevel:            strings.ToUpper(coalesce(os.Getenv("MON_LOG_LEVEL"), "INFO")),
+		redactMode:          redactMode,
+		redactPatterns:      splitLines(os.Getenv("MON_REDACT_PATTERNS")),
+		efficiencyWindow:    durationDefault(os.Getenv("MON_EFFICIENCY_WINDOW"), 5*time.Minute),
+		efficiencyRatio:     floatDefault(os.Getenv("MON_EFFICIENCY_RATIO"), 100),
+		efficiencyMinExec:   uint64Default(os.Getenv("MON_EFFICIENCY_MIN_EXEC"), 10),
+		efficiencyTopN:      atoiDefault(os.Getenv("MON_EFFICIENCY_TOP"), 10),
+		catalogFile:           strings.TrimSpace(os.Getenv("MON_CATALOG_FILE")),
+		catalogDisappearAfter: durationDefault(os.Getenv("MON_CATALOG_DISAPPEAR_AFTER"), 7*24*time.Hour),
+		catalogSaveInterval:   durationDefault(os.Getenv("MON_CATALOG_SAVE_INTERVAL"), time.Minute),
+		ignoreDigests:       splitList(os.Getenv("MON_IGNORE_DIGESTS")),
+		ignoreSchemas:       splitList(os.Getenv("MON_IGNORE_SCHEMAS")),
+		ignoreUsers:         splitList(os.Getenv("MON_IGNORE_USERS")),
+		ignoreText:          splitLines(os.Getenv("MON_IGNORE_TEXT")),
+		ignoreSelf:          boolEnv(os.Getenv("MON_IGNORE_SELF"), true),
+		budgets:             strings.TrimSpace(os.Getenv("MON_BUDGETS")),
+		budgetWarnAt:        intListDefault(os.Getenv("MON_BUDGET_WARN_AT"), []int{50, 80, 100}),
+		budgetStateFile:     strings.TrimSpace(os.Getenv("MON_BUDGET_STATE_FILE")),
+		reporters:           listDefault(os.Getenv("MON_REPORTERS"), []string{"log"}),
+		reporterQueue:       atoiDefault(os.Getenv("MON_REPORTER_QUEUE"), 256),
+		reporterTimeout:     durationDefault(os.Getenv("MON_REPORTER_TIMEOUT"), 10*time.Second),
+		webhookURLs:         splitList(os.Getenv("MON_WEBHOOK_URLS")),
+		webhookTemplate:     coalesce(os.Getenv("MON_WEBHOOK_TEMPLATE"), "json"),
+		webhookContentType:  coalesce(os.Getenv("MON_WEBHOOK_CONTENT_TYPE"), "application/json"),
+		webhookSecret:       os.Getenv("MON_WEBHOOK_SECRET"),
+		webhookTimeout:      durationDefault(os.Getenv("MON_WEBHOOK_TIMEOUT"), 5*time.Second),
+		webhookRetries:      atoiDefault(os.Getenv("MON_WEBHOOK_RETRIES"), 3),
+		webhookSpoolDir:     strings.TrimSpace(os.Getenv("MON_WEBHOOK_SPOOL_DIR")),
+		webhookSpoolMax:     atoiDefault(os.Getenv("MON_WEBHOOK_SPOOL_MAX"), 1000),
+		metricsTopDigests:   atoiDefault(os.Getenv("MON_METRICS_TOP_DIGESTS"), 20),
+		metricsDigestIdle:   atoiDefault(os.Getenv("MON_METRICS_DIGEST_IDLE"), 60),
+		alertResolveAfter:   atoiDefault(os.Getenv("MON_ALERT_RESOLVE_AFTER"), 3),
+		alertmanagerURL:      strings.TrimRight(strings.TrimSpace(os.Getenv("MON_ALERTMANAGER_URL")), "/"),
+		alertmanagerResend:   durationDefault(os.Getenv("MON_ALERTMANAGER_RESEND"), time.Minute),
+		alertmanagerSeverity: coalesce(os.Getenv("MON_ALERTMANAGER_SEVERITY"), "warning"),
+		alertmanagerLabels:   parseKeyValues(os.Getenv("MON_ALERTMANAGER_LABELS")),
+		alertmanagerTimeout:  durationDefault(os.Getenv("MON_ALERTMANAGER_TIMEOUT"), 5*time.Second),
+		otlpEndpoint:        strings.TrimRight(strings.TrimSpace(os.Getenv("MON_OTLP_ENDPOINT")), "/"),
+		otlpHeaders:         parseKeyValues(os.Getenv("MON_OTLP_HEADERS")),
+		otlpServiceName:     coalesce(os.Getenv("MON_OTLP_SERVICE_NAME"), "database-top-throughput-analyzer"),
+		otlpBatchSize:       atoiDefault(os.Getenv("MON_OTLP_BATCH_SIZE"), 1000),
+		otlpFlushInterval:   durationDefault(os.Getenv("MON_OTLP_FLUSH_INTERVAL"), 10*time.Second),
+		otlpRetries:         atoiDefault(os.Getenv("MON_OTLP_RETRIES"), 3),
+		otlpTimeout:         durationDefault(os.Getenv("MON_OTLP_TIMEOUT"), 5*time.Second),
+		lokiURL:             strings.TrimRight(strings.TrimSpace(os.Getenv("MON_LOKI_URL")), "/"),
+		lokiTenant:          strings.TrimSpace(os.Getenv("MON_LOKI_TENANT")),
+		lokiLabels:          listDefault(os.Getenv("MON_LOKI_LABELS"), []string{"level", "target", "schema"}),
+		lokiStaticLabels:    parseKeyValues(coalesce(os.Getenv("MON_LOKI_STATIC_LABELS"), "job=monitor")),
+		lokiBatchBytes:      atoiDefault(os.Getenv("MON_LOKI_BATCH_BYTES"), 1<<20),
+		lokiBatchWait:       durationDefault(os.Getenv("MON_LOKI_BATCH_WAIT"), time.Second),
+		lokiGzip:            boolEnv(os.Getenv("MON_LOKI_GZIP"), true),
+		lokiRetries:         atoiDefault(os.Getenv("MON_LOKI_RETRIES"), 5),
+		lokiTimeout:         durationDefault(os.Getenv("MON_LOKI_TIMEOUT"), 10*time.Second),
+		statsdAddr:             strings.TrimSpace(os.Getenv("MON_STATSD_ADDR")),
+		statsdFormat:           coalesce(os.Getenv("MON_STATSD_FORMAT"), "statsd"),
+		statsdPrefix:           coalesce(os.Getenv("MON_STATSD_PREFIX"), "mysql_monitor."),
+		statsdTags:             parseKeyValues(os.Getenv("MON_STATSD_TAGS")),
+		statsdTopDigests:       atoiDefault(os.Getenv("MON_STATSD_TOP_DIGESTS"), 10),
+		statsdDigestSampleRate: floatDefault(os.Getenv("MON_STATSD_DIGEST_SAMPLE_RATE"), 1),
+		statsdMTU:              atoiDefault(os.Getenv("MON_STATSD_MTU"), 1432),
+		summaryPeriods:      splitList(os.Getenv("MON_SUMMARY_PERIODS")),
+		summaryDir:          coalesce(os.Getenv("MON_SUMMARY_DIR"), "reports"),
+		summaryFormats:      listDefault(os.Getenv("MON_SUMMARY_FORMATS"), []string{"md", "html", "csv"}),
+		summaryTop:          atoiDefault(os.Getenv("MON_SUMMARY_TOP"), 20),
+		summaryNotify:       boolEnv(os.Getenv("MON_SUMMARY_NOTIFY"), true),
+		httpAddr:             coalesce(os.Getenv("MON_HTTP_ADDR"), ":8088"),
+		httpTLSCert:          strings.TrimSpace(os.Getenv("MON_HTTP_TLS_CERT")),
+		httpTLSKey:           strings.TrimSpace(os.Getenv("MON_HTTP_TLS_KEY")),
+		httpBasicAuth:        splitList(os.Getenv("MON_HTTP_BASIC_AUTH")),
+		httpBearerTokens:     splitList(os.Getenv("MON_HTTP_BEARER_TOKENS")),
+		httpAuthExemptHealth: boolEnv(os.Getenv("MON_HTTP_AUTH_EXEMPT_HEALTH"), true),
+		httpAnonymousWrites:  boolEnv(os.Getenv("MON_HTTP_ANONYMOUS_WRITES"), false),
+		apiHistory:          atoiDefault(os.Getenv("MON_API_HISTORY"), 60),
+		readyMaxIntervals:   atoiDefault(os.Getenv("MON_READY_MAX_INTERVALS"), 3),
+		configFile:          os.Getenv("MON_CONFIG_FILE"),
+		silenceFile:         strings.TrimSpace(os.Getenv("MON_SILENCE_FILE")),
+		silenceSaveInterval: durationDefault(os.Getenv("MON_SILENCE_SAVE_INTERVAL"), time.Minute),
+		sseMaxClients:       atoiDefault(os.Getenv("MON_SSE_MAX_CLIENTS"), 100),
+		sseBuffer:           atoiDefault(os.Getenv("MON_SSE_BUFFER"), 256),
+		sseSlowTimeout:      durationDefault(os.Getenv("MON_SSE_SLOW_TIMEOUT"), 30*time.Second),
+		sseLogRing:          atoiDefault(os.Getenv("MON_SSE_LOG_RING"), 2048),
+		sseEventRing:        atoiDefault(os.Getenv("MON_SSE_EVENT_RING"), 1024),
 	}
 }
 
 // Getters
-func (c *config) DSN() string                 { return c.dsn }
-func (c *config) Interval() time.Duration     { return c.interval }
-func (c *config) ReadThreshold() uint64       { return c.readThreshold }
-func (c *config) WriteThreshold() uint64      { return c.writeThreshold }
-func (c *config) AvgRowRead() uint64          { return c.avgRowRead }
-func (c *config) AvgRowSent() uint64          { return c.avgRowSent }
-func (c *config) TopN() int                   { return c.topN }
-func (c *config) RealIO() bool                { return c.realIO }
-func (c *config) MinPrintBytes() uint64       { return c.minPrintBytes }
-func (c *config) ReadRowsThreshold() uint64   { return c.readRowsThreshold }
-func (c *config) WriteRowsThreshold() uint64  { return c.writeRowsThreshold }
-func (c *config) MinPrintRows() uint64        { return c.minPrintRows }
-func (c *config) Simple() bool                { return c.simple }
+func (c *config) DSN() string                 { c.mu.RLock(); defer c.mu.RUnlock(); return c.dsn }
+func (c *config) Interval() time.Duration     { c.mu.RLock(); defer c.mu.RUnlock(); return c.interval }
+func (c *config) ReadThreshold() uint64       { c.mu.RLock(); defer c.mu.RUnlock(); return c.readThreshold }
+func (c *config) WriteThreshold() uint64      { c.mu.RLock(); defer c.mu.RUnlock(); return c.writeThreshold }
+func (c *config) AvgRowRead() uint64          { c.mu.RLock(); defer c.mu.RUnlock(); return c.avgRowRead }
+func (c *config) AvgRowSent() uint64          { c.mu.RLock(); defer c.mu.RUnlock(); return c.avgRowSent }
+func (c *config) TopN() int                   { c.mu.RLock(); defer c.mu.RUnlock(); return c.topN }
+func (c *config) RealIO() bool                { c.mu.RLock(); defer c.mu.RUnlock(); return c.realIO }
+func (c *config) MinPrintBytes() uint64       { c.mu.RLock(); defer c.mu.RUnlock(); return c.minPrintBytes }
+func (c *config) ReadRowsThreshold() uint64   { c.mu.RLock(); defer c.mu.RUnlock(); return c.readRowsThreshold }
+func (c *config) WriteRowsThreshold() uint64  { c.mu.RLock(); defer c.mu.RUnlock(); return c.writeRowsThreshold }
+func (c *config) MinPrintRows() uint64        { c.mu.RLock(); defer c.mu.RUnlock(); return c.minPrintRows }
+func (c *config) Simple() bool                { c.mu.RLock(); defer c.mu.RUnlock(); return c.simple }
 // Logging getters
-func (c *config) LogMode() string       { return c.logMode }
-func (c *config) LogFile() string       { return c.logFile }
-func (c *config) LogMaxSizeMB() int     { return c.logMaxSizeMB }
-func (c *config) LogMaxBackups() int    { return c.logMaxBackups }
-func (c *config) LogMaxAgeDays() int    { return c.logMaxAgeDays }
-func (c *config) LogCompress() bool     { return c.logCompress }
-func (c *config) LogLevel() string      { return c.logLevel }
+func (c *config) LogMode() string       { c.mu.RLock(); defer c.mu.RUnlock(); return c.logMode }
+func (c *config) LogFile() string       { c.mu.RLock(); defer c.mu.RUnlock(); return c.logFile }
+func (c *config) LogMaxSizeMB() int     { c.mu.RLock(); defer c.mu.RUnlock(); return c.logMaxSizeMB }
+func (c *config) LogMaxBackups() int    { c.mu.RLock(); defer c.mu.RUnlock(); return c.logMaxBackups }
+func (c *config) LogMaxAgeDays() int    { c.mu.RLock(); defer c.mu.RUnlock(); return c.logMaxAgeDays }
+func (c *config) LogCompress() bool     { c.mu.RLock(); defer c.mu.RUnlock(); return c.logCompress }
+func (c *config) LogLevel() string      { c.mu.RLock(); defer c.mu.RUnlock(); return c.logLevel }
+// Redaction getters
+func (c *config) RedactMode() string       { c.mu.RLock(); defer c.mu.RUnlock(); return c.redactMode }
+func (c *config) RedactPatterns() []string { c.mu.RLock(); defer c.mu.RUnlock(); return c.redactPatterns }
+// Efficiency analysis getters
+func (c *config) EfficiencyWindow() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.efficiencyWindow }
+func (c *config) EfficiencyRatio() float64        { c.mu.RLock(); defer c.mu.RUnlock(); return c.efficiencyRatio }
+func (c *config) EfficiencyMinExec() uint64       { c.mu.RLock(); defer c.mu.RUnlock(); return c.efficiencyMinExec }
+func (c *config) EfficiencyTopN() int             { c.mu.RLock(); defer c.mu.RUnlock(); return c.efficiencyTopN }
+// Digest catalog getters
+func (c *config) CatalogFile() string                  { c.mu.RLock(); defer c.mu.RUnlock(); return c.catalogFile }
+func (c *config) CatalogDisappearAfter() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.catalogDisappearAfter }
+func (c *config) CatalogSaveInterval() time.Duration   { c.mu.RLock(); defer c.mu.RUnlock(); return c.catalogSaveInterval }
+// Ignore rules getters
+func (c *config) IgnoreDigests() []string { c.mu.RLock(); defer c.mu.RUnlock(); return c.ignoreDigests }
+func (c *config) IgnoreSchemas() []string { c.mu.RLock(); defer c.mu.RUnlock(); return c.ignoreSchemas }
+func (c *config) IgnoreUsers() []string   { c.mu.RLock(); defer c.mu.RUnlock(); return c.ignoreUsers }
+func (c *config) IgnoreText() []string    { c.mu.RLock(); defer c.mu.RUnlock(); return c.ignoreText }
+func (c *config) IgnoreSelf() bool        { c.mu.RLock(); defer c.mu.RUnlock(); return c.ignoreSelf }
+// Throughput budgets getters
+func (c *config) Budgets() string         { c.mu.RLock(); defer c.mu.RUnlock(); return c.budgets }
+func (c *config) BudgetWarnAt() []int     { c.mu.RLock(); defer c.mu.RUnlock(); return c.budgetWarnAt }
+func (c *config) BudgetStateFile() string { c.mu.RLock(); defer c.mu.RUnlock(); return c.budgetStateFile }
+// Reporters getters
+func (c *config) Reporters() []string            { c.mu.RLock(); defer c.mu.RUnlock(); return c.reporters }
+func (c *config) ReporterQueue() int             { c.mu.RLock(); defer c.mu.RUnlock(); return c.reporterQueue }
+func (c *config) ReporterTimeout() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.reporterTimeout }
+// Webhook reporter getters
+func (c *config) WebhookURLs() []string         { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookURLs }
+func (c *config) WebhookTemplate() string       { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookTemplate }
+func (c *config) WebhookContentType() string    { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookContentType }
+func (c *config) WebhookSecret() string         { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookSecret }
+func (c *config) WebhookTimeout() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookTimeout }
+func (c *config) WebhookRetries() int           { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookRetries }
+func (c *config) WebhookSpoolDir() string       { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookSpoolDir }
+func (c *config) WebhookSpoolMax() int          { c.mu.RLock(); defer c.mu.RUnlock(); return c.webhookSpoolMax }
+// Metrics getters
+func (c *config) MetricsTopDigests() int { c.mu.RLock(); defer c.mu.RUnlock(); return c.metricsTopDigests }
+func (c *config) MetricsDigestIdle() int { c.mu.RLock(); defer c.mu.RUnlock(); return c.metricsDigestIdle }
+// Alert lifecycle getters
+func (c *config) AlertResolveAfter() int { c.mu.RLock(); defer c.mu.RUnlock(); return c.alertResolveAfter }
+// Alertmanager reporter getters
+func (c *config) AlertmanagerURL() string               { c.mu.RLock(); defer c.mu.RUnlock(); return c.alertmanagerURL }
+func (c *config) AlertmanagerResend() time.Duration     { c.mu.RLock(); defer c.mu.RUnlock(); return c.alertmanagerResend }
+func (c *config) AlertmanagerSeverity() string          { c.mu.RLock(); defer c.mu.RUnlock(); return c.alertmanagerSeverity }
+func (c *config) AlertmanagerLabels() map[string]string { c.mu.RLock(); defer c.mu.RUnlock(); return c.alertmanagerLabels }
+func (c *config) AlertmanagerTimeout() time.Duration    { c.mu.RLock(); defer c.mu.RUnlock(); return c.alertmanagerTimeout }
+// OTLP export getters
+func (c *config) OTLPEndpoint() string             { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpEndpoint }
+func (c *config) OTLPHeaders() map[string]string   { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpHeaders }
+func (c *config) OTLPServiceName() string          { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpServiceName }
+func (c *config) OTLPBatchSize() int               { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpBatchSize }
+func (c *config) OTLPFlushInterval() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpFlushInterval }
+func (c *config) OTLPRetries() int                 { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpRetries }
+func (c *config) OTLPTimeout() time.Duration       { c.mu.RLock(); defer c.mu.RUnlock(); return c.otlpTimeout }
+// Loki push getters
+func (c *config) LokiURL() string                     { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiURL }
+func (c *config) LokiTenant() string                  { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiTenant }
+func (c *config) LokiLabels() []string                { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiLabels }
+func (c *config) LokiStaticLabels() map[string]string { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiStaticLabels }
+func (c *config) LokiBatchBytes() int                 { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiBatchBytes }
+func (c *config) LokiBatchWait() time.Duration        { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiBatchWait }
+func (c *config) LokiGzip() bool                      { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiGzip }
+func (c *config) LokiRetries() int                    { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiRetries }
+func (c *config) LokiTimeout() time.Duration          { c.mu.RLock(); defer c.mu.RUnlock(); return c.lokiTimeout }
+// StatsD getters
+func (c *config) StatsdAddr() string              { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdAddr }
+func (c *config) StatsdFormat() string            { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdFormat }
+func (c *config) StatsdPrefix() string            { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdPrefix }
+func (c *config) StatsdTags() map[string]string   { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdTags }
+func (c *config) StatsdTopDigests() int           { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdTopDigests }
+func (c *config) StatsdDigestSampleRate() float64 { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdDigestSampleRate }
+func (c *config) StatsdMTU() int                  { c.mu.RLock(); defer c.mu.RUnlock(); return c.statsdMTU }
+// Summary reports getters
+func (c *config) SummaryPeriods() []string { c.mu.RLock(); defer c.mu.RUnlock(); return c.summaryPeriods }
+func (c *config) SummaryDir() string       { c.mu.RLock(); defer c.mu.RUnlock(); return c.summaryDir }
+func (c *config) SummaryFormats() []string { c.mu.RLock(); defer c.mu.RUnlock(); return c.summaryFormats }
+func (c *config) SummaryTop() int          { c.mu.RLock(); defer c.mu.RUnlock(); return c.summaryTop }
+func (c *config) SummaryNotify() bool      { c.mu.RLock(); defer c.mu.RUnlock(); return c.summaryNotify }
+// HTTP server getters
+func (c *config) HTTPAddr() string           { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpAddr }
+func (c *config) HTTPTLSCert() string        { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpTLSCert }
+func (c *config) HTTPTLSKey() string         { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpTLSKey }
+func (c *config) HTTPBasicAuth() []string    { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpBasicAuth }
+func (c *config) HTTPBearerTokens() []string { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpBearerTokens }
+func (c *config) HTTPAuthExemptHealth() bool { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpAuthExemptHealth }
+func (c *config) HTTPAnonymousWrites() bool  { c.mu.RLock(); defer c.mu.RUnlock(); return c.httpAnonymousWrites }
+// JSON API getters
+func (c *config) APIHistory() int { c.mu.RLock(); defer c.mu.RUnlock(); return c.apiHistory }
+// Readiness getters
+func (c *config) ReadyMaxIntervals() int { c.mu.RLock(); defer c.mu.RUnlock(); return c.readyMaxIntervals }
+// Runtime configuration getters
+func (c *config) ConfigFile() string { c.mu.RLock(); defer c.mu.RUnlock(); return c.configFile }
+// Silences getters
+func (c *config) SilenceFile() string                { c.mu.RLock(); defer c.mu.RUnlock(); return c.silenceFile }
+func (c *config) SilenceSaveInterval() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.silenceSaveInterval }
+// SSE streaming getters
+func (c *config) SSEMaxClients() int            { c.mu.RLock(); defer c.mu.RUnlock(); return c.sseMaxClients }
+func (c *config) SSEBuffer() int                { c.mu.RLock(); defer c.mu.RUnlock(); return c.sseBuffer }
+func (c *config) SSESlowTimeout() time.Duration { c.mu.RLock(); defer c.mu.RUnlock(); return c.sseSlowTimeout }
+func (c *config) SSELogRing() int               { c.mu.RLock(); defer c.mu.RUnlock(); return c.sseLogRing }
+func (c *config) SSEEventRing() int             { c.mu.RLock(); defer c.mu.RUnlock(); return c.sseEventRing }
 
 // Setters
-func (c *config) SetDSN(v string)                  { c.dsn = v }
-func (c *config) SetInterval(v time.Duration)      { c.interval = v }
-func (c *config) SetReadThreshold(v uint64)        { c.readThreshold = v }
-func (c *config) SetWriteThreshold(v uint64)       { c.writeThreshold = v }
-func (c *config) SetAvgRowRead(v uint64)           { c.avgRowRead = v }
-func (c *config) SetAvgRowSent(v uint64)           { c.avgRowSent = v }
-func (c *config) SetTopN(v int)                    { c.topN = v }
-func (c *config) SetRealIO(v bool)                 { c.realIO = v }
-func (c *config) SetMinPrintBytes(v uint64)        { c.minPrintBytes = v }
-func (c *config) SetReadRowsThreshold(v uint64)    { c.readRowsThreshold = v }
-func (c *config) SetWriteRowsThreshold(v uint64)   { c.writeRowsThreshold = v }
-func (c *config) SetMinPrintRows(v uint64)         { c.minPrintRows = v }
-func (c *config) SetSimple(v bool)                 { c.simple = v }
+func (c *config) SetDSN(v string)                  { c.mu.Lock(); defer c.mu.Unlock(); c.dsn = v }
+func (c *config) SetInterval(v time.Duration)      { c.mu.Lock(); defer c.mu.Unlock(); c.interval = v }
+func (c *config) SetReadThreshold(v uint64)        { c.mu.Lock(); defer c.mu.Unlock(); c.readThreshold = v }
+func (c *config) SetWriteThreshold(v uint64)       { c.mu.Lock(); defer c.mu.Unlock(); c.writeThreshold = v }
+func (c *config) SetAvgRowRead(v uint64)           { c.mu.Lock(); defer c.mu.Unlock(); c.avgRowRead = v }
+func (c *config) SetAvgRowSent(v uint64)           { c.mu.Lock(); defer c.mu.Unlock(); c.avgRowSent = v }
+func (c *config) SetTopN(v int)                    { c.mu.Lock(); defer c.mu.Unlock(); c.topN = v }
+func (c *config) SetRealIO(v bool)                 { c.mu.Lock(); defer c.mu.Unlock(); c.realIO = v }
+func (c *config) SetMinPrintBytes(v uint64)        { c.mu.Lock(); defer c.mu.Unlock(); c.minPrintBytes = v }
+func (c *config) SetReadRowsThreshold(v uint64)    { c.mu.Lock(); defer c.mu.Unlock(); c.readRowsThreshold = v }
+func (c *config) SetWriteRowsThreshold(v uint64)   { c.mu.Lock(); defer c.mu.Unlock(); c.writeRowsThreshold = v }
+func (c *config) SetMinPrintRows(v uint64)         { c.mu.Lock(); defer c.mu.Unlock(); c.minPrintRows = v }
+func (c *config) SetSimple(v bool)                 { c.mu.Lock(); defer c.mu.Unlock(); c.simple = v }
 // Logging setters
-func (c *config) SetLogMode(v string)       { c.logMode = v }
-func (c *config) SetLogFile(v string)       { c.logFile = v }
-func (c *config) SetLogMaxSizeMB(v int)     { c.logMaxSizeMB = v }
-func (c *config) SetLogMaxBackups(v int)    { c.logMaxBackups = v }
-func (c *config) SetLogMaxAgeDays(v int)    { c.logMaxAgeDays = v }
-func (c *config) SetLogCompress(v bool)     { c.logCompress = v }
-func (c *config) SetLogLevel(v string)      { c.logLevel = v }
+func (c *config) SetLogMode(v string)       { c.mu.Lock(); defer c.mu.Unlock(); c.logMode = v }
+func (c *config) SetLogFile(v string)       { c.mu.Lock(); defer c.mu.Unlock(); c.logFile = v }
+func (c *config) SetLogMaxSizeMB(v int)     { c.mu.Lock(); defer c.mu.Unlock(); c.logMaxSizeMB = v }
+func (c *config) SetLogMaxBackups(v int)    { c.mu.Lock(); defer c.mu.Unlock(); c.logMaxBackups = v }
+func (c *config) SetLogMaxAgeDays(v int)    { c.mu.Lock(); defer c.mu.Unlock(); c.logMaxAgeDays = v }
+func (c *config) SetLogCompress(v bool)     { c.mu.Lock(); defer c.mu.Unlock(); c.logCompress = v }
+func (c *config) SetLogLevel(v string)      { c.mu.Lock(); defer c.mu.Unlock(); c.logLevel = v }
+// Redaction setters
+func (c *config) SetRedactMode(v string)       { c.mu.Lock(); defer c.mu.Unlock(); c.redactMode = v }
+func (c *config) SetRedactPatterns(v []string) { c.mu.Lock(); defer c.mu.Unlock(); c.redactPatterns = v }
+// Efficiency analysis setters
+func (c *config) SetEfficiencyWindow(v time.Duration)  { c.mu.Lock(); defer c.mu.Unlock(); c.efficiencyWindow = v }
+func (c *config) SetEfficiencyRatio(v float64)         { c.mu.Lock(); defer c.mu.Unlock(); c.efficiencyRatio = v }
+func (c *config) SetEfficiencyMinExec(v uint64)        { c.mu.Lock(); defer c.mu.Unlock(); c.efficiencyMinExec = v }
+func (c *config) SetEfficiencyTopN(v int)              { c.mu.Lock(); defer c.mu.Unlock(); c.efficiencyTopN = v }
+// Digest catalog setters
+func (c *config) SetCatalogFile(v string)                   { c.mu.Lock(); defer c.mu.Unlock(); c.catalogFile = v }
+func (c *config) SetCatalogDisappearAfter(v time.Duration)  { c.mu.Lock(); defer c.mu.Unlock(); c.catalogDisappearAfter = v }
+func (c *config) SetCatalogSaveInterval(v time.Duration)    { c.mu.Lock(); defer c.mu.Unlock(); c.catalogSaveInterval = v }
+// Ignore rules setters
+func (c *config) SetIgnoreDigests(v []string)  { c.mu.Lock(); defer c.mu.Unlock(); c.ignoreDigests = v }
+func (c *config) SetIgnoreSchemas(v []string)  { c.mu.Lock(); defer c.mu.Unlock(); c.ignoreSchemas = v }
+func (c *config) SetIgnoreUsers(v []string)    { c.mu.Lock(); defer c.mu.Unlock(); c.ignoreUsers = v }
+func (c *config) SetIgnoreText(v []string)     { c.mu.Lock(); defer c.mu.Unlock(); c.ignoreText = v }
+func (c *config) SetIgnoreSelf(v bool)         { c.mu.Lock(); defer c.mu.Unlock(); c.ignoreSelf = v }
+// Throughput budgets setters
+func (c *config) SetBudgets(v string)          { c.mu.Lock(); defer c.mu.Unlock(); c.budgets = v }
+func (c *config) SetBudgetWarnAt(v []int)      { c.mu.Lock(); defer c.mu.Unlock(); c.budgetWarnAt = v }
+func (c *config) SetBudgetStateFile(v string)  { c.mu.Lock(); defer c.mu.Unlock(); c.budgetStateFile = v }
+// Reporters setters
+func (c *config) SetReporters(v []string)             { c.mu.Lock(); defer c.mu.Unlock(); c.reporters = v }
+func (c *config) SetReporterQueue(v int)              { c.mu.Lock(); defer c.mu.Unlock(); c.reporterQueue = v }
+func (c *config) SetReporterTimeout(v time.Duration)  { c.mu.Lock(); defer c.mu.Unlock(); c.reporterTimeout = v }
+// Webhook reporter setters
+func (c *config) SetWebhookURLs(v []string)          { c.mu.Lock(); defer c.mu.Unlock(); c.webhookURLs = v }
+func (c *config) SetWebhookTemplate(v string)        { c.mu.Lock(); defer c.mu.Unlock(); c.webhookTemplate = v }
+func (c *config) SetWebhookContentType(v string)     { c.mu.Lock(); defer c.mu.Unlock(); c.webhookContentType = v }
+func (c *config) SetWebhookSecret(v string)          { c.mu.Lock(); defer c.mu.Unlock(); c.webhookSecret = v }
+func (c *config) SetWebhookTimeout(v time.Duration)  { c.mu.Lock(); defer c.mu.Unlock(); c.webhookTimeout = v }
+func (c *config) SetWebhookRetries(v int)            { c.mu.Lock(); defer c.mu.Unlock(); c.webhookRetries = v }
+func (c *config) SetWebhookSpoolDir(v string)        { c.mu.Lock(); defer c.mu.Unlock(); c.webhookSpoolDir = v }
+func (c *config) SetWebhookSpoolMax(v int)           { c.mu.Lock(); defer c.mu.Unlock(); c.webhookSpoolMax = v }
+// Metrics setters
+func (c *config) SetMetricsTopDigests(v int)  { c.mu.Lock(); defer c.mu.Unlock(); c.metricsTopDigests = v }
+func (c *config) SetMetricsDigestIdle(v int)  { c.mu.Lock(); defer c.mu.Unlock(); c.metricsDigestIdle = v }
+// Alert lifecycle setters
+func (c *config) SetAlertResolveAfter(v int)  { c.mu.Lock(); defer c.mu.Unlock(); c.alertResolveAfter = v }
+// Alertmanager reporter setters
+func (c *config) SetAlertmanagerURL(v string)                { c.mu.Lock(); defer c.mu.Unlock(); c.alertmanagerURL = v }
+func (c *config) SetAlertmanagerResend(v time.Duration)      { c.mu.Lock(); defer c.mu.Unlock(); c.alertmanagerResend = v }
+func (c *config) SetAlertmanagerSeverity(v string)           { c.mu.Lock(); defer c.mu.Unlock(); c.alertmanagerSeverity = v }
+func (c *config) SetAlertmanagerLabels(v map[string]string)  { c.mu.Lock(); defer c.mu.Unlock(); c.alertmanagerLabels = v }
+func (c *config) SetAlertmanagerTimeout(v time.Duration)     { c.mu.Lock(); defer c.mu.Unlock(); c.alertmanagerTimeout = v }
+// OTLP export setters
+func (c *config) SetOTLPEndpoint(v string)              { c.mu.Lock(); defer c.mu.Unlock(); c.otlpEndpoint = v }
+func (c *config) SetOTLPHeaders(v map[string]string)    { c.mu.Lock(); defer c.mu.Unlock(); c.otlpHeaders = v }
+func (c *config) SetOTLPServiceName(v string)           { c.mu.Lock(); defer c.mu.Unlock(); c.otlpServiceName = v }
+func (c *config) SetOTLPBatchSize(v int)                { c.mu.Lock(); defer c.mu.Unlock(); c.otlpBatchSize = v }
+func (c *config) SetOTLPFlushInterval(v time.Duration)  { c.mu.Lock(); defer c.mu.Unlock(); c.otlpFlushInterval = v }
+func (c *config) SetOTLPRetries(v int)                  { c.mu.Lock(); defer c.mu.Unlock(); c.otlpRetries = v }
+func (c *config) SetOTLPTimeout(v time.Duration)        { c.mu.Lock(); defer c.mu.Unlock(); c.otlpTimeout = v }
+// Loki push setters
+func (c *config) SetLokiURL(v string)                      { c.mu.Lock(); defer c.mu.Unlock(); c.lokiURL = v }
+func (c *config) SetLokiTenant(v string)                   { c.mu.Lock(); defer c.mu.Unlock(); c.lokiTenant = v }
+func (c *config) SetLokiLabels(v []string)                 { c.mu.Lock(); defer c.mu.Unlock(); c.lokiLabels = v }
+func (c *config) SetLokiStaticLabels(v map[string]string)  { c.mu.Lock(); defer c.mu.Unlock(); c.lokiStaticLabels = v }
+func (c *config) SetLokiBatchBytes(v int)                  { c.mu.Lock(); defer c.mu.Unlock(); c.lokiBatchBytes = v }
+func (c *config) SetLokiBatchWait(v time.Duration)         { c.mu.Lock(); defer c.mu.Unlock(); c.lokiBatchWait = v }
+func (c *config) SetLokiGzip(v bool)                       { c.mu.Lock(); defer c.mu.Unlock(); c.lokiGzip = v }
+func (c *config) SetLokiRetries(v int)                     { c.mu.Lock(); defer c.mu.Unlock(); c.lokiRetries = v }
+func (c *config) SetLokiTimeout(v time.Duration)           { c.mu.Lock(); defer c.mu.Unlock(); c.lokiTimeout = v }
+// StatsD setters
+func (c *config) SetStatsdAddr(v string)               { c.mu.Lock(); defer c.mu.Unlock(); c.statsdAddr = v }
+func (c *config) SetStatsdFormat(v string)             { c.mu.Lock(); defer c.mu.Unlock(); c.statsdFormat = v }
+func (c *config) SetStatsdPrefix(v string)             { c.mu.Lock(); defer c.mu.Unlock(); c.statsdPrefix = v }
+func (c *config) SetStatsdTags(v map[string]string)    { c.mu.Lock(); defer c.mu.Unlock(); c.statsdTags = v }
+func (c *config) SetStatsdTopDigests(v int)            { c.mu.Lock(); defer c.mu.Unlock(); c.statsdTopDigests = v }
+func (c *config) SetStatsdDigestSampleRate(v float64)  { c.mu.Lock(); defer c.mu.Unlock(); c.statsdDigestSampleRate = v }
+func (c *config) SetStatsdMTU(v int)                   { c.mu.Lock(); defer c.mu.Unlock(); c.statsdMTU = v }
+// Summary reports setters
+func (c *config) SetSummaryPeriods(v []string)  { c.mu.Lock(); defer c.mu.Unlock(); c.summaryPeriods = v }
+func (c *config) SetSummaryDir(v string)        { c.mu.Lock(); defer c.mu.Unlock(); c.summaryDir = v }
+func (c *config) SetSummaryFormats(v []string)  { c.mu.Lock(); defer c.mu.Unlock(); c.summaryFormats = v }
+func (c *config) SetSummaryTop(v int)           { c.mu.Lock(); defer c.mu.Unlock(); c.summaryTop = v }
+func (c *config) SetSummaryNotify(v bool)       { c.mu.Lock(); defer c.mu.Unlock(); c.summaryNotify = v }
+// HTTP server setters
+func (c *config) SetHTTPAddr(v string)            { c.mu.Lock(); defer c.mu.Unlock(); c.httpAddr = v }
+func (c *config) SetHTTPTLSCert(v string)         { c.mu.Lock(); defer c.mu.Unlock(); c.httpTLSCert = v }
+func (c *config) SetHTTPTLSKey(v string)          { c.mu.Lock(); defer c.mu.Unlock(); c.httpTLSKey = v }
+func (c *config) SetHTTPBasicAuth(v []string)     { c.mu.Lock(); defer c.mu.Unlock(); c.httpBasicAuth = v }
+func (c *config) SetHTTPBearerTokens(v []string)  { c.mu.Lock(); defer c.mu.Unlock(); c.httpBearerTokens = v }
+func (c *config) SetHTTPAuthExemptHealth(v bool)  { c.mu.Lock(); defer c.mu.Unlock(); c.httpAuthExemptHealth = v }
+func (c *config) SetHTTPAnonymousWrites(v bool)   { c.mu.Lock(); defer c.mu.Unlock(); c.httpAnonymousWrites = v }
+// JSON API setters
+func (c *config) SetAPIHistory(v int)  { c.mu.Lock(); defer c.mu.Unlock(); c.apiHistory = v }
+// Readiness setters
+func (c *config) SetReadyMaxIntervals(v int)  { c.mu.Lock(); defer c.mu.Unlock(); c.readyMaxIntervals = v }
+// Runtime configuration setters
+func (c *config) SetConfigFile(v string)  { c.mu.Lock(); defer c.mu.Unlock(); c.configFile = v }
+// Silences setters
+func (c *config) SetSilenceFile(v string)                { c.mu.Lock(); defer c.mu.Unlock(); c.silenceFile = v }
+func (c *config) SetSilenceSaveInterval(v time.Duration) { c.mu.Lock(); defer c.mu.Unlock(); c.silenceSaveInterval = v }
+// SSE streaming setters
+func (c *config) SetSSEMaxClients(v int)             { c.mu.Lock(); defer c.mu.Unlock(); c.sseMaxClients = v }
+func (c *config) SetSSEBuffer(v int)                 { c.mu.Lock(); defer c.mu.Unlock(); c.sseBuffer = v }
+func (c *config) SetSSESlowTimeout(v time.Duration)  { c.mu.Lock(); defer c.mu.Unlock(); c.sseSlowTimeout = v }
+func (c *config) SetSSELogRing(v int)                { c.mu.Lock(); defer c.mu.Unlock(); c.sseLogRing = v }
+func (c *config) SetSSEEventRing(v int)              { c.mu.Lock(); defer c.mu.Unlock(); c.sseEventRing = v }
 
 // helpers for env parsing
 func coalesce(v, def string) string {
@@ -428,3 +1024,68 @@ func boolEnv(v string, def bool) bool {
 	if v == "" { return def }
 	return v == "1" || v == "true" || v == "yes" || v == "on"
 }
+func durationDefault(v string, def time.Duration) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" { return def }
+	if d, err := time.ParseDuration(v); err == nil { return d }
+	return def
+}
+func floatDefault(v string, def float64) float64 {
+	v = strings.TrimSpace(v)
+	if v == "" { return def }
+	if f, err := strconv.ParseFloat(v, 64); err == nil { return f }
+	return def
+}
+func uint64Default(v string, def uint64) uint64 {
+	v = strings.TrimSpace(v)
+	if v == "" { return def }
+	if n, err := strconv.ParseUint(v, 10, 64); err == nil { return n }
+	return def
+}
+func intListDefault(v string, def []int) []int {
+	var out []int
+	for _, item := range splitList(v) {
+		n, err := strconv.Atoi(item)
+		if err != nil { return def }
+		out = append(out, n)
+	}
+	if len(out) == 0 { return def }
+	return out
+}
+// splitList splits a comma-separated env value, dropping blank items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" { out = append(out, item) }
+	}
+	return out
+}
+func listDefault(v string, def []string) []string {
+	if out := splitList(v); len(out) > 0 { return out }
+	return def
+}
+// parseKeyValues parses "k=v,k2=v2" into a map, skipping malformed items.
+func parseKeyValues(v string) map[string]string {
+	out := map[string]string{}
+	for _, item := range splitList(v) {
+		if k, val, ok := strings.Cut(item, "="); ok && strings.TrimSpace(k) != "" { out[strings.TrimSpace(k)] = strings.TrimSpace(val) }
+	}
+	return out
+}
+// splitLines splits a newline-separated env value, dropping blank lines.
+func splitLines(v string) []string {
+	var out []string
+	for _, line := range strings.Split(v, "\n") {
+		if line = strings.TrimSpace(line); line != "" { out = append(out, line) }
+	}
+	return out
+}
+// parseLogMode normalizes MON_LOG_MODE, rejecting anything but stdout|file|both.
+func parseLogMode(v string) (string, error) {
+	mode := strings.ToLower(coalesce(v, "stdout"))
+	switch mode {
+	case "stdout", "file", "both":
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid log mode %q (want stdout|file|both)", v)
+}
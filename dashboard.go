@@ -0,0 +1,27 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+// dashboardFS holds the single-page dashboard served at /. It only uses the
+// JSON API and /events, so it works wherever those do.
+//
+//go:embed web/index.html
+var dashboardFS embed.FS
+
+// dashboardHandler serves the embedded dashboard.
+func dashboardHandler() http.HandlerFunc {
+	page, err := dashboardFS.ReadFile("web/index.html")
+	if err != nil {
+		panic(err) // embedded at build time
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; img-src 'self' data:")
+		_, _ = w.Write(page)
+	}
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"sync"
+
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -15,6 +18,16 @@ import (
 type DBClient interface {
 	Ping(ctx context.Context) error
 	Snapshot(ctx context.Context) (snapshot, error)
+	// OwnDigests returns the digests of the statements the client itself runs
+	// (needs STATEMENT_DIGEST(), MySQL 8.0.4+).
+	OwnDigests(ctx context.Context) ([]string, error)
+	// UserDigests splits digests found in the statement history tables into
+	// those executed by any of the given users and those executed by others.
+	// Statements of threads that ended before the client ever saw them alive
+	// cannot be attributed and are left out of both.
+	UserDigests(ctx context.Context, users []string) (byUsers, byOthers map[string]struct{}, err error)
+	// EngineIO returns the server's cumulative InnoDB data read/written bytes.
+	EngineIO(ctx context.Context) (engineIO, error)
 	Close() error
 }
 
@@ -23,7 +36,12 @@ type DBClient interface {
 
 type snapKey string
 
+// newSnapKey builds the map key for a digest row; performance_schema keeps one
+// row per (SCHEMA_NAME, DIGEST), so the same digest may appear once per schema.
+func newSnapKey(schema, digest string) snapKey { return snapKey(schema + "/" + digest) }
+
 type digestStat struct {
+	Schema      string // SCHEMA_NAME, empty when no default schema was selected
 	Digest      string
 	DigestText  string
 	QuerySample sql.NullString // real sample SQL if available (MySQL 8.0+: QUERY_SAMPLE_TEXT)
@@ -35,9 +53,20 @@ type digestStat struct {
 
 type snapshot map[snapKey]digestStat
 
+// engineIO holds cumulative InnoDB data I/O counters in bytes (Innodb_data_read / Innodb_data_written).
+type engineIO struct {
+	Read    uint64
+	Written uint64
+}
+
 // mysqlClient is the hidden implementation of DBClient
 
-type mysqlClient struct{ db *sql.DB }
+type mysqlClient struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	threadUsers map[uint64]string // THREAD_ID -> user, remembered while history rows reference it
+}
 
 // NewMySQLClient constructs a DBClient backed by MySQL
 func NewMySQLClient(dsn string) (DBClient, error) {
@@ -49,19 +78,37 @@ func NewMySQLClient(dsn string) (DBClient, error) {
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(0)
-	return &mysqlClient{db: db}, nil
+	return &mysqlClient{db: db, threadUsers: make(map[uint64]string)}, nil
 }
 
 func (c *mysqlClient) Close() error { return c.db.Close() }
 
 func (c *mysqlClient) Ping(ctx context.Context) error { return c.db.PingContext(ctx) }
 
-func (c *mysqlClient) Snapshot(ctx context.Context) (snapshot, error) {
-	const q = `
-SELECT DIGEST, DIGEST_TEXT, QUERY_SAMPLE_TEXT, COUNT_STAR, SUM_ROWS_EXAMINED, SUM_ROWS_SENT, SUM_ROWS_AFFECTED
+// Statements run by the client; listed so OwnDigests can exclude them from rankings.
+const (
+	snapshotQuery = `
+SELECT SCHEMA_NAME, DIGEST, DIGEST_TEXT, QUERY_SAMPLE_TEXT, COUNT_STAR, SUM_ROWS_EXAMINED, SUM_ROWS_SENT, SUM_ROWS_AFFECTED
 FROM performance_schema.events_statements_summary_by_digest
 WHERE DIGEST IS NOT NULL`
-	rows, err := c.db.QueryContext(ctx, q)
+	statementDigestQuery = `SELECT STATEMENT_DIGEST(?)`
+	// history_long outlives the threads; alive is 0 once they disconnect
+	userDigestsQuery = `
+SELECT h.THREAD_ID, h.DIGEST, t.THREAD_ID IS NOT NULL AS alive, t.PROCESSLIST_USER
+FROM (SELECT THREAD_ID, DIGEST FROM performance_schema.events_statements_history
+      UNION ALL
+      SELECT THREAD_ID, DIGEST FROM performance_schema.events_statements_history_long) h
+LEFT JOIN performance_schema.threads t ON t.THREAD_ID = h.THREAD_ID
+WHERE h.DIGEST IS NOT NULL
+GROUP BY h.THREAD_ID, h.DIGEST, alive, t.PROCESSLIST_USER`
+	engineIOQuery = `
+SELECT VARIABLE_NAME, VARIABLE_VALUE
+FROM performance_schema.global_status
+WHERE VARIABLE_NAME IN ('Innodb_data_read', 'Innodb_data_written')`
+)
+
+func (c *mysqlClient) Snapshot(ctx context.Context) (snapshot, error) {
+	rows, err := c.db.QueryContext(ctx, snapshotQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -69,10 +116,12 @@ WHERE DIGEST IS NOT NULL`
 	snap := make(snapshot)
 	for rows.Next() {
 		var d digestStat
-		if err := rows.Scan(&d.Digest, &d.DigestText, &d.QuerySample, &d.CountStar, &d.SumRowsExam, &d.SumRowsSent, &d.SumRowsAff); err != nil {
+		var schema sql.NullString
+		if err := rows.Scan(&schema, &d.Digest, &d.DigestText, &d.QuerySample, &d.CountStar, &d.SumRowsExam, &d.SumRowsSent, &d.SumRowsAff); err != nil {
 			return nil, err
 		}
-		snap[snapKey(d.Digest)] = d
+		d.Schema = schema.String
+		snap[newSnapKey(d.Schema, d.Digest)] = d
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -80,4 +129,84 @@ WHERE DIGEST IS NOT NULL`
 	return snap, nil
 }
 
-// EngineIO removed in slimmed build
+func (c *mysqlClient) OwnDigests(ctx context.Context) ([]string, error) {
+	var out []string
+	for _, q := range []string{snapshotQuery, statementDigestQuery, userDigestsQuery, engineIOQuery} {
+		var d sql.NullString
+		if err := c.db.QueryRowContext(ctx, statementDigestQuery, q).Scan(&d); err != nil {
+			return nil, err
+		}
+		if d.Valid && d.String != "" {
+			out = append(out, d.String)
+		}
+	}
+	return out, nil
+}
+
+func (c *mysqlClient) UserDigests(ctx context.Context, users []string) (map[string]struct{}, map[string]struct{}, error) {
+	want := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		want[strings.ToLower(u)] = struct{}{}
+	}
+	rows, err := c.db.QueryContext(ctx, userDigestsQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byUsers, byOthers := make(map[string]struct{}), make(map[string]struct{})
+	referenced := make(map[uint64]string)
+	for rows.Next() {
+		var thread uint64
+		var digest string
+		var alive bool
+		var user sql.NullString
+		if err := rows.Scan(&thread, &digest, &alive, &user); err != nil {
+			return nil, nil, err
+		}
+		// Disconnected threads keep the user captured while they were alive
+		who, known := user.String, alive
+		if !alive {
+			who, known = c.threadUsers[thread]
+		}
+		if !known {
+			continue
+		}
+		referenced[thread] = who
+		if _, ok := want[strings.ToLower(who)]; ok {
+			byUsers[digest] = struct{}{}
+		} else {
+			byOthers[digest] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	// Threads whose rows have left the history tables are not needed anymore
+	c.threadUsers = referenced
+	return byUsers, byOthers, nil
+}
+
+func (c *mysqlClient) EngineIO(ctx context.Context) (engineIO, error) {
+	var out engineIO
+	rows, err := c.db.QueryContext(ctx, engineIOQuery)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var value uint64
+		if err := rows.Scan(&name, &value); err != nil {
+			return out, err
+		}
+		switch strings.ToLower(name) {
+		case "innodb_data_read":
+			out.Read = value
+		case "innodb_data_written":
+			out.Written = value
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// efficiencyAnalyzer accumulates per-digest row counts over a window and
+// reports digests that examine far more rows than they return - the classic
+// signature of a missing index. Rows returned = rows sent + rows affected, so
+// UPDATE/DELETE statements scanning a table to touch a handful of rows are
+// caught as well.
+type efficiencyAnalyzer struct {
+	log      *slog.Logger
+	window   time.Duration
+	minRatio float64
+	minExec  uint64
+	topN     int
+
+	start time.Time
+	acc   map[snapKey]*efficiencyAcc
+}
+
+type efficiencyAcc struct {
+	digest   string
+	text     string
+	count    uint64
+	examined uint64
+	returned uint64
+}
+
+// efficiencyRow is one ranked entry of an inefficient-queries report.
+type efficiencyRow struct {
+	Digest          string
+	Text            string
+	Count           uint64
+	RowsExamined    uint64
+	RowsReturned    uint64
+	Ratio           float64 // rows examined per row returned
+	ExaminedPerExec float64
+	ReturnedPerExec float64
+	WastedRows      uint64 // rows examined beyond those returned
+}
+
+func newEfficiencyAnalyzer(cfg Config, log *slog.Logger) *efficiencyAnalyzer {
+	return &efficiencyAnalyzer{
+		log:      log,
+		window:   cfg.EfficiencyWindow(),
+		minRatio: cfg.EfficiencyRatio(),
+		minExec:  cfg.EfficiencyMinExec(),
+		topN:     cfg.EfficiencyTopN(),
+		acc:      make(map[snapKey]*efficiencyAcc),
+	}
+}
+
+func (a *efficiencyAnalyzer) ObserveInterval(t tick) {
+	if a.start.IsZero() {
+		a.start = t.At.Add(-t.Elapsed)
+	}
+	for k, d := range t.Delta {
+		e := a.acc[k]
+		if e == nil {
+			e = &efficiencyAcc{digest: d.Digest}
+			a.acc[k] = e
+		}
+		e.text = sampleText(d)
+		e.count += d.CountStar
+		e.examined += d.SumRowsExam
+		e.returned += d.SumRowsSent + d.SumRowsAff
+	}
+	if t.At.Sub(a.start) < a.window {
+		return
+	}
+	a.report(t.At.Sub(a.start), a.rank())
+	a.start = t.At
+	a.acc = make(map[snapKey]*efficiencyAcc)
+}
+
+// rank returns flagged digests ordered by wasted rows (descending), capped at topN.
+func (a *efficiencyAnalyzer) rank() []efficiencyRow {
+	var rows []efficiencyRow
+	for _, e := range a.acc {
+		if e.count < a.minExec || e.count == 0 {
+			continue
+		}
+		ratio := float64(e.examined) / float64(maxU64(e.returned, 1))
+		if ratio < a.minRatio {
+			continue
+		}
+		wasted := uint64(0)
+		if e.examined > e.returned {
+			wasted = e.examined - e.returned
+		}
+		rows = append(rows, efficiencyRow{
+			Digest:          e.digest,
+			Text:            e.text,
+			Count:           e.count,
+			RowsExamined:    e.examined,
+			RowsReturned:    e.returned,
+			Ratio:           ratio,
+			ExaminedPerExec: float64(e.examined) / float64(e.count),
+			ReturnedPerExec: float64(e.returned) / float64(e.count),
+			WastedRows:      wasted,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].WastedRows > rows[j].WastedRows })
+	if a.topN > 0 && len(rows) > a.topN {
+		rows = rows[:a.topN]
+	}
+	return rows
+}
+
+func (a *efficiencyAnalyzer) report(window time.Duration, rows []efficiencyRow) {
+	a.log.Info("inefficient queries",
+		"window", window.Round(time.Second).String(),
+		"minRatio", a.minRatio,
+		"minExec", a.minExec,
+		"flagged", len(rows),
+	)
+	for i, r := range rows {
+		a.log.Warn("inefficient query",
+			"rank", i+1,
+			"digest", r.Digest,
+			"count", r.Count,
+			"rowsExamined", r.RowsExamined,
+			"rowsReturned", r.RowsReturned,
+			"ratio", roundTo(r.Ratio, 1),
+			"examinedPerExec", roundTo(r.ExaminedPerExec, 1),
+			"returnedPerExec", roundTo(r.ReturnedPerExec, 1),
+			"wastedRows", r.WastedRows,
+			"sample", r.Text,
+		)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventSchemaVersion is bumped on incompatible changes to event payloads.
+const eventSchemaVersion = 1
+
+// Event types published on /events.
+const (
+	eventSnapshot      = "snapshot"
+	eventOffender      = "offender"
+	eventAlert         = "alert"
+	eventAlertResolved = "alert_resolved"
+	eventAlertSilenced = "alert_silenced"
+	eventEngineIO      = "engine_io"
+	eventDBHealth      = "db_health"
+)
+
+var eventTypes = []string{eventSnapshot, eventOffender, eventAlert, eventAlertResolved, eventAlertSilenced, eventEngineIO, eventDBHealth}
+
+// monitorEvent is one published event. Data is the encoded envelope, so every
+// subscriber shares the same bytes.
+type monitorEvent struct {
+	ID      uint64
+	Type    string
+	Data    []byte
+	Dropped uint64 // events this subscriber lost just before this one
+}
+
+// eventEnvelope is the JSON sent in each event's data: field.
+type eventEnvelope struct {
+	Version int       `json:"v"`
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data"`
+}
+
+type snapshotEventData struct {
+	At               time.Time `json:"at"`
+	ElapsedSeconds   float64   `json:"elapsedSeconds"`
+	TookSeconds      float64   `json:"tookSeconds"`
+	Digests          int       `json:"digests"`
+	Count            uint64    `json:"count"`
+	BytesRead        uint64    `json:"bytesRead"`
+	BytesWrite       uint64    `json:"bytesWrite"`
+	ExcludedCount    uint64    `json:"excludedCount"`
+	Alerts           int       `json:"alerts"`
+	Silenced         int       `json:"silenced"`
+	Resolved         int       `json:"resolved"`
+	OffendersEmitted int       `json:"offendersEmitted"`
+}
+
+type alertEventData struct {
+	apiOffender
+	ReadThreshold  uint64 `json:"readThreshold"`
+	WriteThreshold uint64 `json:"writeThreshold"`
+}
+
+type alertSilencedEventData struct {
+	alertEventData
+	SilenceID string `json:"silenceId"`
+}
+
+type alertResolvedEventData struct {
+	Schema string `json:"schema"`
+	Digest string `json:"digest"`
+	Sample string `json:"sample"`
+}
+
+type engineIOEventData struct {
+	TotalRead        uint64  `json:"totalRead"`
+	TotalWritten     uint64  `json:"totalWritten"`
+	DeltaRead        uint64  `json:"deltaRead"`
+	DeltaWritten     uint64  `json:"deltaWritten"`
+	ReadPerSecond    float64 `json:"readPerSecond"`
+	WrittenPerSecond float64 `json:"writtenPerSecond"`
+}
+
+type dbHealthEventData struct {
+	Status              string `json:"status"` // ok|failing
+	Error               string `json:"error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+}
+
+// eventStream is an intervalObserver that turns every tick into typed events
+// (independent of log level and formatting), keeps the most recent ones for
+// Last-Event-ID replay and fans them out to /events subscribers.
+type eventStream struct {
+	cfg Config
+	log *slog.Logger
+
+	mu      sync.Mutex
+	ring    []monitorEvent
+	cap     int
+	next    uint64 // id of the next event; ids start at 1
+	subs    map[chan monitorEvent]*sseBacklog
+	failing int // consecutive snapshot failures
+}
+
+func newEventStream(cfg Config, log *slog.Logger) *eventStream {
+	return &eventStream{cfg: cfg, log: log, cap: max(cfg.SSEEventRing(), 1), next: 1, subs: make(map[chan monitorEvent]*sseBacklog)}
+}
+
+// publish encodes data under typ and hands it to the ring and every subscriber.
+// Subscribers whose buffer is full lose it, as on /logs (see sseBroadcaster.Broadcast).
+func (e *eventStream) publish(at time.Time, typ string, data any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := e.next
+	b, err := json.Marshal(eventEnvelope{Version: eventSchemaVersion, ID: id, Type: typ, Time: at.UTC(), Data: data})
+	if err != nil {
+		e.log.Warn("event encode failed", "type", typ, "err", err)
+		return
+	}
+	e.next++
+	ev := monitorEvent{ID: id, Type: typ, Data: b}
+	if len(e.ring) == e.cap {
+		e.ring = e.ring[1:]
+	}
+	e.ring = append(e.ring, ev)
+	now, limit := time.Now(), e.cfg.SSESlowTimeout()
+	for ch, backlog := range e.subs {
+		ev.Dropped = backlog.dropped
+		select {
+		case ch <- ev:
+			backlog.caughtUp()
+		default:
+			if backlog.lost(1, now, limit) {
+				delete(e.subs, ch)
+				close(ch)
+				sseEvicted.Add(1)
+			}
+		}
+	}
+}
+
+func (e *eventStream) subscribe() (chan monitorEvent, func()) {
+	ch := make(chan monitorEvent, max(e.cfg.SSEBuffer(), 1))
+	e.mu.Lock()
+	e.subs[ch] = &sseBacklog{}
+	e.mu.Unlock()
+	return ch, func() {
+		e.mu.Lock()
+		if _, ok := e.subs[ch]; ok {
+			delete(e.subs, ch)
+			close(ch)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// since returns retained events with id >= from, the id of the first retained
+// event at or after from (greater than from when some were evicted) and the
+// next id to be assigned.
+func (e *eventStream) since(from uint64) ([]monitorEvent, uint64, uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	first := e.next
+	if len(e.ring) > 0 {
+		first = e.ring[0].ID
+	}
+	first = max(first, from)
+	var out []monitorEvent
+	for _, ev := range e.ring {
+		if ev.ID >= first {
+			out = append(out, ev)
+		}
+	}
+	return out, first, e.next
+}
+
+func (e *eventStream) ObserveInterval(t tick) {
+	e.mu.Lock()
+	recovered := e.failing > 0
+	e.failing = 0
+	e.mu.Unlock()
+	if recovered {
+		e.publish(t.At, eventDBHealth, dbHealthEventData{Status: "ok"})
+	}
+
+	offenders := make([]apiOffender, 0, len(t.Delta))
+	snap := snapshotEventData{
+		At:             t.At.UTC(),
+		ElapsedSeconds: roundTo(t.Elapsed.Seconds(), 3),
+		TookSeconds:    roundTo(t.Took.Seconds(), 3),
+		Digests:        len(t.Delta),
+		ExcludedCount:  t.Excluded.Total().Count,
+		Alerts:         len(t.Alerts),
+		Silenced:       len(t.Silenced),
+		Resolved:       len(t.Resolved),
+	}
+	alerting := make(map[snapKey]bool, len(t.Alerts))
+	for _, a := range t.Alerts {
+		alerting[newSnapKey(a.Schema, a.Digest)] = true
+	}
+	silenced := make(map[snapKey]bool, len(t.Silenced))
+	for _, a := range t.Silenced {
+		silenced[newSnapKey(a.Schema, a.Digest)] = true
+	}
+	for k, d := range t.Delta {
+		o := newAPIOffender(e.cfg, d, t.Elapsed)
+		o.Alerting = alerting[k]
+		o.Silenced = silenced[k]
+		snap.Count += o.Count
+		snap.BytesRead += o.BytesRead
+		snap.BytesWrite += o.BytesWrite
+		offenders = append(offenders, o)
+	}
+	// Offenders are the top MON_TOP digests by estimated bytes with any traffic
+	sort.Slice(offenders, func(i, j int) bool {
+		return offenders[i].BytesRead+offenders[i].BytesWrite > offenders[j].BytesRead+offenders[j].BytesWrite
+	})
+	n := 0
+	for n < len(offenders) && n < e.cfg.TopN() && offenders[n].BytesRead+offenders[n].BytesWrite > 0 {
+		n++
+	}
+	offenders = offenders[:n]
+	snap.OffendersEmitted = n
+	e.publish(t.At, eventSnapshot, snap)
+
+	byKey := make(map[snapKey]apiOffender, len(offenders))
+	for _, o := range offenders {
+		byKey[newSnapKey(o.Schema, o.Digest)] = o
+		e.publish(t.At, eventOffender, o)
+	}
+	alertData := func(a offender) alertEventData {
+		k := newSnapKey(a.Schema, a.Digest)
+		o, ok := byKey[k]
+		if !ok {
+			if d, inDelta := t.Delta[k]; inDelta {
+				o = newAPIOffender(e.cfg, d, t.Elapsed)
+			} else {
+				o = apiOffender{Schema: a.Schema, Digest: a.Digest, Sample: a.Text, apiCounts: apiCounts{Count: a.Count, RowsExamined: a.RowsExamined, RowsSent: a.RowsSent, BytesRead: a.BytesRead, BytesWrite: a.BytesWrite}}
+			}
+		}
+		o.Alerting, o.Silenced = alerting[k], silenced[k]
+		return alertEventData{apiOffender: o, ReadThreshold: e.cfg.ReadThreshold(), WriteThreshold: e.cfg.WriteThreshold()}
+	}
+	for _, a := range t.Alerts {
+		e.publish(t.At, eventAlert, alertData(a))
+	}
+	for _, a := range t.Silenced {
+		e.publish(t.At, eventAlertSilenced, alertSilencedEventData{alertEventData: alertData(a.offender), SilenceID: a.SilenceID})
+	}
+	for _, r := range t.Resolved {
+		e.publish(t.At, eventAlertResolved, alertResolvedEventData{Schema: r.Schema, Digest: r.Digest, Sample: r.Text})
+	}
+	if t.Engine != nil {
+		eio := engineIOEventData{
+			TotalRead:    t.Engine.Total.Read,
+			TotalWritten: t.Engine.Total.Written,
+			DeltaRead:    t.Engine.Delta.Read,
+			DeltaWritten: t.Engine.Delta.Written,
+		}
+		if secs := t.Elapsed.Seconds(); secs > 0 {
+			eio.ReadPerSecond = roundTo(float64(eio.DeltaRead)/secs, 1)
+			eio.WrittenPerSecond = roundTo(float64(eio.DeltaWritten)/secs, 1)
+		}
+		e.publish(t.At, eventEngineIO, eio)
+	}
+}
+
+func (e *eventStream) SnapshotFailed(at time.Time, err error) {
+	e.mu.Lock()
+	e.failing++
+	n := e.failing
+	e.mu.Unlock()
+	e.publish(at, eventDBHealth, dbHealthEventData{Status: "failing", Error: err.Error(), ConsecutiveFailures: n})
+}
+
+// ServeHTTP streams events as SSE: "event: <type>", "id: <id>" and the
+// envelope as data. ?types=alert,alert_resolved limits the types sent;
+// Last-Event-ID or ?since= replay retained events, as on /logs.
+func (e *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	want, err := parseEventTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resumeFrom, resume, err := sseResumeFrom(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if !sseAcquire(e.cfg.SSEMaxClients()) {
+		e.log.Warn("event stream client rejected, too many clients", "remote", r.RemoteAddr, "limit", e.cfg.SSEMaxClients())
+		sseReject(w)
+		return
+	}
+	defer sseClients.Add(-1)
+	e.log.Info("event stream client connected", "remote", r.RemoteAddr)
+	defer e.log.Info("event stream client disconnected", "remote", r.RemoteAddr)
+
+	ch, unsubscribe := e.subscribe()
+	defer unsubscribe()
+
+	bw := bufio.NewWriter(w)
+	send := func(ev monitorEvent) error {
+		if want != nil && !want[ev.Type] {
+			return nil
+		}
+		_, err := fmt.Fprintf(bw, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+		return err
+	}
+	hello, _ := json.Marshal(map[string]any{"v": eventSchemaVersion, "types": eventTypes})
+	fmt.Fprintf(bw, "retry: %d\nevent: hello\ndata: %s\n\n", sseRetry.Milliseconds(), hello)
+	var lastID uint64
+	if resume {
+		events, first, next := e.since(resumeFrom)
+		if first > resumeFrom {
+			if err := writeSSEGap(bw, resumeFrom, first); err != nil {
+				return
+			}
+		}
+		for _, ev := range events {
+			if err := send(ev); err != nil {
+				return
+			}
+		}
+		lastID = next - 1
+	}
+	if bw.Flush() != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := bw.WriteString(": keepalive\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-ch:
+			if !ok {
+				// Only publish closes it early: this client stayed behind too long
+				e.log.Warn("event stream client too slow, disconnected", "remote", r.RemoteAddr)
+				return
+			}
+			if ev.ID <= lastID {
+				continue
+			}
+			if ev.Dropped > 0 {
+				if err := writeSSEDropped(bw, ev.Dropped); err != nil {
+					return
+				}
+			}
+			if err := send(ev); err != nil {
+				return
+			}
+		}
+		if bw.Flush() != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// parseEventTypes parses ?types=; nil means all types.
+func parseEventTypes(v string) (map[string]bool, error) {
+	if v == "" {
+		return nil, nil
+	}
+	want := make(map[string]bool)
+	for _, t := range strings.Split(v, ",") {
+		t = strings.TrimSpace(t)
+		if !containsString(eventTypes, t) {
+			return nil, fmt.Errorf("unknown event type %q (known: %s)", t, strings.Join(eventTypes, ", "))
+		}
+		want[t] = true
+	}
+	return want, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eventIDs(evs []monitorEvent) []uint64 {
+	var ids []uint64
+	for _, ev := range evs {
+		ids = append(ids, ev.ID)
+	}
+	return ids
+}
+
+func TestEventStreamSince(t *testing.T) {
+	// Ring of 3 after 5 events: 3..5 are retained, 6 is next
+	e := newEventStream(testConfig(func(c *config) { c.sseEventRing = 3 }), testLogger())
+	for i := 0; i < 5; i++ {
+		e.publish(time.Now(), "alert", nil)
+	}
+	for _, tc := range []struct {
+		from        uint64
+		ids         []uint64
+		first, next uint64
+	}{
+		{1, []uint64{3, 4, 5}, 3, 6}, // 1 and 2 were evicted
+		{3, []uint64{3, 4, 5}, 3, 6},
+		{5, []uint64{5}, 5, 6},
+		{6, nil, 6, 6},
+		{9, nil, 9, 6},
+	} {
+		evs, first, next := e.since(tc.from)
+		if ids := eventIDs(evs); !reflect.DeepEqual(ids, tc.ids) || first != tc.first || next != tc.next {
+			t.Errorf("since(%d) = %v, %d, %d; want %v, %d, %d", tc.from, ids, first, next, tc.ids, tc.first, tc.next)
+		}
+	}
+}
+
+func TestEventStreamReplayThenLive(t *testing.T) {
+	e := newEventStream(testConfig(func(c *config) { c.sseEventRing = 3; c.sseBuffer = 16 }), testLogger())
+	for i := 0; i < 5; i++ {
+		e.publish(time.Now(), "alert", nil)
+	}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	go func() {
+		// Published once the client is subscribed; it must be streamed once,
+		// after the replayed ones
+		for {
+			e.mu.Lock()
+			n := len(e.subs)
+			e.mu.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		e.publish(time.Now(), "alert", nil)
+	}()
+	got := readSSE(t, srv.URL, http.Header{"Last-Event-Id": {"1"}}, func(l string) bool { return l == "id: 6" })
+	var ids []string
+	for _, l := range got {
+		if strings.HasPrefix(l, "id: ") || l == "event: gap" {
+			ids = append(ids, l)
+		}
+	}
+	if want := []string{"event: gap", "id: 3", "id: 4", "id: 5", "id: 6"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("stream = %v, want %v", ids, want)
+	}
+}
+
+func TestEventStreamEvictsSlowSubscriber(t *testing.T) {
+	e := newEventStream(testConfig(func(c *config) { c.sseBuffer = 1; c.sseSlowTimeout = 20 * time.Millisecond }), testLogger())
+	ch, unsubscribe := e.subscribe()
+	defer unsubscribe()
+	before := sseEvicted.Load()
+
+	e.publish(time.Now(), "alert", nil) // fills the buffer
+	e.publish(time.Now(), "alert", nil) // lost
+	<-ch
+	e.publish(time.Now(), "alert", nil)
+	if ev := <-ch; ev.ID != 3 || ev.Dropped != 1 {
+		t.Fatalf("after catching up got id %d dropped %d, want id 3 with 1 dropped", ev.ID, ev.Dropped)
+	}
+
+	e.publish(time.Now(), "alert", nil) // fills the buffer again
+	e.publish(time.Now(), "alert", nil) // starts falling behind
+	time.Sleep(30 * time.Millisecond)
+	e.publish(time.Now(), "alert", nil) // still behind past the timeout
+	<-ch
+	if _, open := <-ch; open {
+		t.Fatal("slow subscriber was not disconnected")
+	}
+	if n := sseEvicted.Load() - before; n != 1 {
+		t.Errorf("evicted = %d, want 1", n)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// readyCheck is one condition of /readyz.
+type readyCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// readiness evaluates the monitor's state: the DB answered the last snapshot,
+// the last successful snapshot is recent, and snapshots keep up with the interval.
+func (a *apiState) readiness(now time.Time) (bool, map[string]readyCheck) {
+	a.mu.RLock()
+	h := a.health
+	a.mu.RUnlock()
+	interval := a.cfg.Interval()
+	maxAge := time.Duration(max(a.cfg.ReadyMaxIntervals(), 1)) * interval
+
+	checks := make(map[string]readyCheck, 3)
+	switch {
+	case h.ConsecFailing > 0:
+		checks["db"] = readyCheck{false, fmt.Sprintf("%d consecutive snapshot failures: %s", h.ConsecFailing, h.LastError)}
+	case h.LastSuccess.IsZero():
+		checks["db"] = readyCheck{false, "no snapshot taken yet"}
+	default:
+		checks["db"] = readyCheck{true, "last snapshot succeeded"}
+	}
+	if h.LastSuccess.IsZero() {
+		checks["snapshot"] = readyCheck{false, "no successful snapshot yet"}
+	} else {
+		age := now.Sub(h.LastSuccess)
+		checks["snapshot"] = readyCheck{age <= maxAge, fmt.Sprintf("last success %s ago (limit %s)", age.Round(time.Millisecond), maxAge)}
+	}
+	// The ticker drops ticks while a snapshot runs, so one slower than the
+	// interval means intervals are being skipped
+	checks["overrun"] = readyCheck{h.LastTook <= interval, fmt.Sprintf("last snapshot took %s (interval %s)", h.LastTook.Round(time.Millisecond), interval)}
+
+	ready := true
+	for _, c := range checks {
+		ready = ready && c.OK
+	}
+	return ready, checks
+}
+
+// GET /healthz: the process is up and serving.
+func (a *apiState) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":     "ok",
+		"uptime":     time.Since(a.started).Round(time.Second).String(),
+		"goroutines": runtime.NumGoroutine(),
+	})
+}
+
+// GET /readyz: 200 when every readiness check passes, 503 otherwise.
+func (a *apiState) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	ready, checks := a.readiness(time.Now())
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
+
+// runHealthcheck queries this monitor's own /readyz (or /healthz when live is
+// set) over MON_HTTP_ADDR, prints the body and fails on a non-200 answer. It
+// replaces curl/wget in container healthchecks.
+func runHealthcheck(cfg Config, live bool) error {
+	path := "/readyz"
+	if live {
+		path = "/healthz"
+	}
+	addr := cfg.HTTPAddr()
+	if strings.EqualFold(addr, "off") {
+		return fmt.Errorf("http server is disabled (MON_HTTP_ADDR=off)")
+	}
+	transport := &http.Transport{}
+	host := addr
+	if sock, ok := strings.CutPrefix(addr, "unix:"); ok {
+		host = "localhost"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		}
+	} else {
+		h, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("MON_HTTP_ADDR %q: %w", addr, err)
+		}
+		if ip := net.ParseIP(h); h == "" || (ip != nil && ip.IsUnspecified()) {
+			h = "127.0.0.1"
+		}
+		host = net.JoinHostPort(h, port)
+	}
+	scheme := "http"
+	if cfg.HTTPTLSCert() != "" {
+		// The certificate is issued for the service name, not the loopback address
+		scheme = "https"
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+host+path, nil)
+	if err != nil {
+		return err
+	}
+	if tokens := cfg.HTTPBearerTokens(); len(tokens) > 0 {
+		req.Header.Set("Authorization", "Bearer "+tokens[0])
+	} else if creds := cfg.HTTPBasicAuth(); len(creds) > 0 {
+		user, pass, _ := strings.Cut(creds[0], ":")
+		req.SetBasicAuth(user, pass)
+	}
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return nil
+}
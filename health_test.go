@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		health apiHealth
+		ready  bool
+		failed []string
+	}{
+		{"no snapshot yet", apiHealth{}, false, []string{"db", "snapshot"}},
+		{"recent snapshot", apiHealth{LastSuccess: now.Add(-30 * time.Second), LastTook: time.Second}, true, nil},
+		{"at the age limit", apiHealth{LastSuccess: now.Add(-3 * time.Minute)}, true, nil},
+		{"stale snapshot", apiHealth{LastSuccess: now.Add(-4 * time.Minute)}, false, []string{"snapshot"}},
+		{"db failing", apiHealth{LastSuccess: now.Add(-30 * time.Second), ConsecFailing: 2, LastError: "timeout"}, false, []string{"db"}},
+		{"overrun", apiHealth{LastSuccess: now, LastTook: 90 * time.Second}, false, []string{"overrun"}},
+	} {
+		a := newAPIState(testConfig(func(c *config) { c.interval = time.Minute; c.readyMaxIntervals = 3 }))
+		a.health = tc.health
+		ready, checks := a.readiness(now)
+		if ready != tc.ready {
+			t.Errorf("%s: ready = %v, want %v (%v)", tc.name, ready, tc.ready, checks)
+		}
+		failed := map[string]bool{}
+		for _, name := range tc.failed {
+			failed[name] = true
+		}
+		for name, c := range checks {
+			if c.OK == failed[name] {
+				t.Errorf("%s: check %s ok = %v: %s", tc.name, name, c.OK, c.Detail)
+			}
+		}
+	}
+}
+
+func TestReadyzFollowsSnapshots(t *testing.T) {
+	a := newAPIState(testConfig(func(c *config) { c.interval = time.Minute; c.readyMaxIntervals = 3 }))
+	mux := http.NewServeMux()
+	a.register(mux)
+	readyz := func() (int, string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body struct{ Status string }
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body.Status
+	}
+
+	if code, status := readyz(); code != http.StatusServiceUnavailable || status != "not ready" {
+		t.Errorf("before the first snapshot: %d %q", code, status)
+	}
+	a.ObserveInterval(tick{At: time.Now(), Elapsed: time.Minute, Took: time.Second})
+	if code, status := readyz(); code != http.StatusOK || status != "ready" {
+		t.Errorf("after a snapshot: %d %q", code, status)
+	}
+	a.SnapshotFailed(time.Now(), errors.New("connection refused"))
+	if code, _ := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("after a failed snapshot: %d, want 503", code)
+	}
+	a.ObserveInterval(tick{At: time.Now(), Elapsed: time.Minute, Took: time.Second})
+	if code, _ := readyz(); code != http.StatusOK {
+		t.Errorf("after recovering: %d, want 200", code)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger discards everything.
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// testConfig returns a config with the defaults the tests share (sinks with
+// short timeouts, nothing flushed or re-sent on its own), changed by edit.
+func testConfig(edit func(c *config)) *config {
+	c := &config{
+		dsn:                  "monitor:secret@tcp(db.internal:3306)/",
+		avgRowRead:           100,
+		avgRowSent:           10,
+		metricsTopDigests:    10,
+		webhookTemplate:      "json",
+		webhookContentType:   "application/json",
+		webhookTimeout:       time.Second,
+		webhookSpoolMax:      10,
+		otlpServiceName:      "mysql-monitor",
+		otlpBatchSize:        1000,
+		otlpFlushInterval:    time.Hour,
+		otlpTimeout:          time.Second,
+		alertmanagerResend:   time.Hour,
+		alertmanagerTimeout:  time.Second,
+		alertmanagerSeverity: "warning",
+	}
+	if edit != nil {
+		edit(c)
+	}
+	return c
+}
+
+// httpRecorder is an httptest endpoint that records every request and answers
+// with the next queued status (200 once the queue is empty).
+type httpRecorder struct {
+	srv     *httptest.Server
+	arrived chan string // request paths, as they come in
+
+	mu       sync.Mutex
+	statuses []int
+	reqs     []recordedRequest
+}
+
+type recordedRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+	At     time.Time
+}
+
+func newHTTPRecorder(t *testing.T, statuses ...int) *httpRecorder {
+	h := &httpRecorder{statuses: statuses, arrived: make(chan string, 64)}
+	h.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		h.mu.Lock()
+		h.reqs = append(h.reqs, recordedRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: body, At: time.Now()})
+		status := http.StatusOK
+		if len(h.statuses) > 0 {
+			status, h.statuses = h.statuses[0], h.statuses[1:]
+		}
+		h.mu.Unlock()
+		w.WriteHeader(status)
+		select {
+		case h.arrived <- r.URL.Path:
+		default:
+		}
+	}))
+	t.Cleanup(h.srv.Close)
+	return h
+}
+
+func (h *httpRecorder) URL() string { return h.srv.URL }
+
+func (h *httpRecorder) setStatuses(statuses ...int) {
+	h.mu.Lock()
+	h.statuses = statuses
+	h.mu.Unlock()
+}
+
+// requests returns what was received so far, in order.
+func (h *httpRecorder) requests() []recordedRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]recordedRequest(nil), h.reqs...)
+}
+
+// byPath returns the requests to path, in order.
+func (h *httpRecorder) byPath(path string) []recordedRequest {
+	var out []recordedRequest
+	for _, r := range h.requests() {
+		if r.Path == path {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// wait blocks until a request to path arrives.
+func (h *httpRecorder) wait(t *testing.T, path string, within time.Duration) {
+	t.Helper()
+	deadline := time.After(within)
+	for {
+		select {
+		case p := <-h.arrived:
+			if p == path {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("no request to %s within %s", path, within)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpServer is the monitor's HTTP listener: TCP or a unix socket
+// (MON_HTTP_ADDR=unix:/path), optional TLS whose certificate is re-read when
+// the files change, and basic/bearer authentication in front of every handler.
+type httpServer struct {
+	srv  *http.Server
+	ln   net.Listener
+	addr string
+	log  *slog.Logger
+	errs chan error
+}
+
+// healthPaths may be exempted from authentication so probes need no credentials.
+var healthPaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// certReloadCheck bounds how often the certificate files are stat'ed.
+const certReloadCheck = 10 * time.Second
+
+// newHTTPServer binds the listener. It returns nil when MON_HTTP_ADDR is "off".
+func newHTTPServer(cfg Config, handler http.Handler, log *slog.Logger) (*httpServer, error) {
+	addr := cfg.HTTPAddr()
+	if strings.EqualFold(addr, "off") {
+		return nil, nil
+	}
+	var ln net.Listener
+	var err error
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// A socket left behind by a previous run would make Listen fail
+		if st, serr := os.Stat(path); serr == nil && st.Mode()&fs.ModeSocket != 0 {
+			_ = os.Remove(path)
+		}
+		if ln, err = net.Listen("unix", path); err == nil {
+			err = os.Chmod(path, 0o660)
+		}
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+
+	tlsOn := cfg.HTTPTLSCert() != "" || cfg.HTTPTLSKey() != ""
+	if tlsOn {
+		if cfg.HTTPTLSCert() == "" || cfg.HTTPTLSKey() == "" {
+			ln.Close()
+			return nil, errors.New("both MON_HTTP_TLS_CERT and MON_HTTP_TLS_KEY are required for TLS")
+		}
+		cr := &certReloader{certFile: cfg.HTTPTLSCert(), keyFile: cfg.HTTPTLSKey(), log: log}
+		if err := cr.load(); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		ln = tls.NewListener(ln, &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: cr.get})
+	}
+
+	auth, err := newHTTPAuth(cfg)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	s := &httpServer{
+		srv:  &http.Server{Handler: auth.wrap(handler), ReadHeaderTimeout: 10 * time.Second},
+		ln:   ln,
+		addr: addr,
+		log:  log,
+		errs: make(chan error, 1),
+	}
+	log.Info("http server starting", "addr", addr, "tls", tlsOn, "auth", auth.modes(), "endpoint", "/logs")
+	return s, nil
+}
+
+// Start serves in the background; a failure is reported on Errors.
+func (s *httpServer) Start() {
+	go func() {
+		if err := s.srv.Serve(s.ln); err != nil && err != http.ErrServerClosed {
+			s.errs <- err
+		}
+	}()
+}
+
+// Errors delivers a serve error, if any.
+func (s *httpServer) Errors() <-chan error { return s.errs }
+
+func (s *httpServer) Shutdown(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	if path, ok := strings.CutPrefix(s.addr, "unix:"); ok {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// certReloader serves the key pair from disk and reloads it when either file's
+// modification time changes, so renewed certificates apply without a restart.
+type certReloader struct {
+	certFile, keyFile string
+	log               *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	checkedAt time.Time
+}
+
+func (c *certReloader) load() error {
+	cst, err := os.Stat(c.certFile)
+	if err != nil {
+		return fmt.Errorf("tls cert: %w", err)
+	}
+	kst, err := os.Stat(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls key: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls key pair: %w", err)
+	}
+	c.cert, c.certMod, c.keyMod, c.checkedAt = &cert, cst.ModTime(), kst.ModTime(), time.Now()
+	return nil
+}
+
+func (c *certReloader) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checkedAt) < certReloadCheck {
+		return c.cert, nil
+	}
+	c.checkedAt = time.Now()
+	cst, err1 := os.Stat(c.certFile)
+	kst, err2 := os.Stat(c.keyFile)
+	if err1 != nil || err2 != nil || (cst.ModTime().Equal(c.certMod) && kst.ModTime().Equal(c.keyMod)) {
+		return c.cert, nil
+	}
+	// A half-written renewal fails to parse; keep serving the old pair and retry later
+	if err := c.load(); err != nil {
+		c.log.Warn("tls certificate reload failed, keeping previous", "err", err)
+		return c.cert, nil
+	}
+	c.log.Info("tls certificate reloaded", "cert", c.certFile)
+	return c.cert, nil
+}
+
+// httpAuth checks basic credentials or bearer tokens. With neither configured
+// every request passes.
+type httpAuth struct {
+	basic        map[string]string
+	tokens       []string
+	exemptHealth bool
+}
+
+func newHTTPAuth(cfg Config) (*httpAuth, error) {
+	a := &httpAuth{basic: make(map[string]string), tokens: cfg.HTTPBearerTokens(), exemptHealth: cfg.HTTPAuthExemptHealth()}
+	for _, cred := range cfg.HTTPBasicAuth() {
+		user, pass, ok := strings.Cut(cred, ":")
+		if !ok || user == "" || pass == "" {
+			return nil, errors.New("MON_HTTP_BASIC_AUTH entries must be user:password")
+		}
+		a.basic[user] = pass
+	}
+	return a, nil
+}
+
+func (a *httpAuth) enabled() bool { return len(a.basic) > 0 || len(a.tokens) > 0 }
+
+func (a *httpAuth) modes() []string {
+	var m []string
+	if len(a.basic) > 0 {
+		m = append(m, "basic")
+	}
+	if len(a.tokens) > 0 {
+		m = append(m, "bearer")
+	}
+	if m == nil {
+		m = []string{"none"}
+	}
+	return m
+}
+
+func (a *httpAuth) wrap(next http.Handler) http.Handler {
+	if !a.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		who, ok := a.allowed(r)
+		if ok {
+			r = r.WithContext(context.WithValue(r.Context(), callerKey{}, who))
+		}
+		if ok || (a.exemptHealth && healthPaths[r.URL.Path]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(a.basic) > 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="monitor", charset="UTF-8"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="monitor"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// callerKey is the request context key under which wrap stores who was authenticated.
+type callerKey struct{}
+
+// authenticatedCaller returns the basic auth user or "bearer" once httpAuth has
+// verified the request's credentials, and "" otherwise.
+func authenticatedCaller(r *http.Request) string {
+	who, _ := r.Context().Value(callerKey{}).(string)
+	return who
+}
+
+// requireWriteAuth rejects a request with 403 unless httpAuth verified its
+// caller. It guards the APIs that change what the monitor reports: with HTTP
+// auth off, anyone who can reach the listener could otherwise raise thresholds
+// or add ignore rules. MON_HTTP_ANONYMOUS_WRITES opts out on trusted networks.
+func requireWriteAuth(cfg Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authenticatedCaller(r) == "" && !cfg.HTTPAnonymousWrites() {
+			writeJSONError(w, http.StatusForbidden,
+				"changes need HTTP authentication (MON_HTTP_BASIC_AUTH or MON_HTTP_BEARER_TOKENS), or MON_HTTP_ANONYMOUS_WRITES=true")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowed accepts basic credentials, an Authorization: Bearer token, or the
+// token as ?access_token= (EventSource cannot set headers). It returns the
+// basic auth user, or "bearer" for a token.
+func (a *httpAuth) allowed(r *http.Request) (string, bool) {
+	if user, pass, ok := r.BasicAuth(); ok {
+		want, known := a.basic[user]
+		// Compare even for unknown users so timing does not reveal valid names
+		match := subtle.ConstantTimeCompare([]byte(pass), []byte(want)) == 1
+		return user, known && match
+	}
+	token := r.URL.Query().Get("access_token")
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		token = strings.TrimSpace(h[7:])
+	}
+	if token == "" {
+		return "", false
+	}
+	ok := false
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	return "bearer", ok
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DigestFilter drops digests that should never show up in rankings or alerts:
+// ignore rules by digest, schema, user and DIGEST_TEXT regex, plus the
+// monitor's own statements. Everything dropped is counted per reason so the
+// exclusions are visible rather than silent.
+type DigestFilter interface {
+	// Refresh updates DB-derived rules (own digests, per-user digests). It is rate limited internally.
+	Refresh(ctx context.Context, db DBClient, now time.Time)
+	// Filter removes ignored entries from stats in place and returns what was removed.
+	Filter(stats map[snapKey]digestStat) exclusionStats
+	// Reload replaces the ignore settings with the current ones in cfg.
+	Reload(cfg Config) error
+}
+
+// Exclusion reasons reported in exclusionStats.
+const (
+	excludeSelf   = "self"
+	excludeDigest = "digest"
+	excludeSchema = "schema"
+	excludeUser   = "user"
+	excludeText   = "text"
+)
+
+// userDigestRefresh bounds how often the statement history tables are queried.
+const userDigestRefresh = 30 * time.Second
+
+// otherSeenExpiry is how long a digest run by a user that is not ignored stays
+// attributed to everyone; after that only ignored users running it counts again.
+const otherSeenExpiry = 24 * time.Hour
+
+// selfTextPattern recognizes the monitor's own statements by DIGEST_TEXT when
+// STATEMENT_DIGEST() is unavailable (MariaDB, MySQL < 8.0.4).
+var selfTextPattern = regexp.MustCompile("(?i)`?performance_schema`?\\s*\\.\\s*`?(events_statements_summary_by_digest|events_statements_history|global_status)`?|STATEMENT_DIGEST\\s*\\(")
+
+// exclusionCount is the traffic removed for one reason in one interval.
+type exclusionCount struct {
+	Digests      uint64
+	Count        uint64
+	RowsExamined uint64
+	RowsSent     uint64
+	RowsAffected uint64
+}
+
+// exclusionStats maps an exclusion reason to the traffic it removed.
+type exclusionStats map[string]exclusionCount
+
+// Total sums all reasons.
+func (s exclusionStats) Total() exclusionCount {
+	var t exclusionCount
+	for _, c := range s {
+		t.Digests += c.Digests
+		t.Count += c.Count
+		t.RowsExamined += c.RowsExamined
+		t.RowsSent += c.RowsSent
+		t.RowsAffected += c.RowsAffected
+	}
+	return t
+}
+
+// digestFilter is the hidden implementation of DigestFilter.
+type digestFilter struct {
+	log     *slog.Logger
+	digests map[string]struct{}
+	schemas map[string]struct{}
+	users   []string
+	texts   []*regexp.Regexp
+	self    bool
+
+	mu          sync.Mutex
+	ownDigests  map[string]struct{}
+	ownResolved bool
+	selfByText  bool
+	userDigests map[string]struct{}  // executed only by ignored users
+	otherSeen   map[string]time.Time // last seen executed by anyone else
+	refreshedAt time.Time
+}
+
+// NewDigestFilter constructs a DigestFilter from the ignore settings in cfg.
+func NewDigestFilter(cfg Config, log *slog.Logger) (DigestFilter, error) {
+	f := &digestFilter{
+		log:         log,
+		ownDigests:  make(map[string]struct{}),
+		userDigests: make(map[string]struct{}),
+		otherSeen:   make(map[string]time.Time),
+	}
+	if err := f.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *digestFilter) Reload(cfg Config) error {
+	digests := make(map[string]struct{})
+	for _, d := range cfg.IgnoreDigests() {
+		digests[d] = struct{}{}
+	}
+	schemas := make(map[string]struct{})
+	for _, s := range cfg.IgnoreSchemas() {
+		schemas[strings.ToLower(s)] = struct{}{}
+	}
+	var texts []*regexp.Regexp
+	for _, p := range cfg.IgnoreText() {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("ignore text pattern %q: %w", p, err)
+		}
+		texts = append(texts, re)
+	}
+	users := cfg.IgnoreUsers()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if strings.Join(users, ",") != strings.Join(f.users, ",") {
+		// Attribution depends on the user list; start over with the next refresh
+		f.userDigests = make(map[string]struct{})
+		f.otherSeen = make(map[string]time.Time)
+		f.refreshedAt = time.Time{}
+	}
+	f.digests, f.schemas, f.texts, f.users, f.self = digests, schemas, texts, users, cfg.IgnoreSelf()
+	return nil
+}
+
+func (f *digestFilter) Refresh(ctx context.Context, db DBClient, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.self && !f.ownResolved {
+		f.ownResolved = true
+		own, err := db.OwnDigests(ctx)
+		if err != nil || len(own) == 0 {
+			f.selfByText = true
+			f.log.Info("self-exclusion by DIGEST_TEXT (STATEMENT_DIGEST unavailable)", "err", err)
+		} else {
+			for _, d := range own {
+				f.ownDigests[d] = struct{}{}
+			}
+			f.log.Debug("self-exclusion digests resolved", "digests", own)
+		}
+	}
+	if len(f.users) == 0 || now.Sub(f.refreshedAt) < userDigestRefresh {
+		return
+	}
+	f.refreshedAt = now
+	byUsers, byOthers, err := db.UserDigests(ctx, f.users)
+	if err != nil {
+		f.log.Warn("ignore users: statement history query failed", "err", err)
+		return
+	}
+	// A digest is only attributed to ignored users while nobody else has been seen running it.
+	for d := range byOthers {
+		f.otherSeen[d] = now
+		delete(f.userDigests, d)
+	}
+	for d, at := range f.otherSeen {
+		if now.Sub(at) > otherSeenExpiry {
+			delete(f.otherSeen, d)
+		}
+	}
+	for d := range byUsers {
+		if _, ok := f.otherSeen[d]; !ok {
+			f.userDigests[d] = struct{}{}
+		}
+	}
+}
+
+func (f *digestFilter) Filter(stats map[snapKey]digestStat) exclusionStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out exclusionStats
+	for k, d := range stats {
+		reason := f.match(d)
+		if reason == "" {
+			continue
+		}
+		delete(stats, k)
+		if out == nil {
+			out = make(exclusionStats)
+		}
+		c := out[reason]
+		c.Digests++
+		c.Count += d.CountStar
+		c.RowsExamined += d.SumRowsExam
+		c.RowsSent += d.SumRowsSent
+		c.RowsAffected += d.SumRowsAff
+		out[reason] = c
+	}
+	return out
+}
+
+// match returns the exclusion reason for d, or "" when d is kept.
+func (f *digestFilter) match(d digestStat) string {
+	if f.self {
+		if _, ok := f.ownDigests[d.Digest]; ok {
+			return excludeSelf
+		}
+		if f.selfByText && selfTextPattern.MatchString(d.DigestText) {
+			return excludeSelf
+		}
+	}
+	if _, ok := f.digests[d.Digest]; ok {
+		return excludeDigest
+	}
+	if _, ok := f.schemas[strings.ToLower(d.Schema)]; ok {
+		return excludeSchema
+	}
+	if _, ok := f.userDigests[d.Digest]; ok {
+		return excludeUser
+	}
+	for _, re := range f.texts {
+		if re.MatchString(d.DigestText) {
+			return excludeText
+		}
+	}
+	return ""
+}
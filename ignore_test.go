@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// historyDB is a DBClient whose statement history attribution is set by the test.
+type historyDB struct {
+	DBClient
+	byUsers, byOthers map[string]struct{}
+}
+
+func (h *historyDB) UserDigests(context.Context, []string) (map[string]struct{}, map[string]struct{}, error) {
+	return h.byUsers, h.byOthers, nil
+}
+
+func TestUserDigestsForgetOtherUsersAfterExpiry(t *testing.T) {
+	f, err := NewDigestFilter(&config{ignoreUsers: []string{"backup"}}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &historyDB{byUsers: map[string]struct{}{"d1": {}}, byOthers: map[string]struct{}{"d1": {}}}
+	ignored := func() bool {
+		stats := map[snapKey]digestStat{newSnapKey("", "d1"): {Digest: "d1", CountStar: 1}}
+		return f.Filter(stats)[excludeUser].Digests == 1
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	f.Refresh(context.Background(), db, now)
+	if ignored() {
+		t.Fatal("digest run by another user was ignored")
+	}
+	// Only the ignored user runs it from now on
+	db.byOthers = nil
+	now = now.Add(time.Hour)
+	f.Refresh(context.Background(), db, now)
+	if ignored() {
+		t.Error("digest ignored while another user ran it recently")
+	}
+	now = now.Add(otherSeenExpiry)
+	f.Refresh(context.Background(), db, now)
+	if !ignored() {
+		t.Error("digest still kept after nobody else ran it for the expiry window")
+	}
+}
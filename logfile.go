@@ -0,0 +1,226 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFile is an io.Writer for MON_LOG_MODE=file|both. It rotates the
+// log when it would grow past maxSize, renaming it to
+// <name>-<timestamp><ext> (gzipped in the background when compress is on) and
+// pruning rotated files beyond maxBackups or older than maxAge. Reopen closes
+// and reopens the path so external logrotate (copy/move + SIGHUP) works too.
+type rotatingFile struct {
+	path       string
+	maxSize    int64 // bytes, 0 = no size rotation
+	maxBackups int   // 0 = keep all
+	maxAge     time.Duration
+	compress   bool
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	pending int // rotations whose compression has not finished
+
+	bg sync.Mutex     // serializes compression and pruning
+	wg sync.WaitGroup // background compression
+}
+
+// rotatedTimeFormat is filename-safe and sorts chronologically.
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// newRotatingFile opens (or creates) path for appending.
+func newRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int, compress bool) (*rotatingFile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("log file path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) << 20,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		compress:   compress,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.prune()
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, st.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			// Keep writing to the current file rather than losing logs
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside and starts a new one. Caller holds mu.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	rotated := r.backupName(time.Now())
+	if err := os.Rename(r.path, rotated); err != nil {
+		_ = r.open()
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.pending++
+	r.wg.Add(1)
+	go r.finish(rotated)
+	return nil
+}
+
+// finish compresses one rotated file and, once no other rotation is still
+// waiting to be compressed, prunes the backups, so prune never removes a file
+// that is being (or about to be) gzipped.
+func (r *rotatingFile) finish(rotated string) {
+	defer r.wg.Done()
+	r.bg.Lock()
+	defer r.bg.Unlock()
+	if r.compress {
+		if err := gzipFile(rotated); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "log compression failed: %v\n", err)
+		}
+	}
+	r.mu.Lock()
+	r.pending--
+	last := r.pending == 0
+	r.mu.Unlock()
+	if last {
+		r.prune()
+	}
+}
+
+func (r *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext) + "-" + t.Format(rotatedTimeFormat) + ext
+}
+
+// prune removes rotated files beyond maxBackups (newest kept) or older than maxAge.
+func (r *rotatingFile) prune() {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return
+	}
+	type backup struct {
+		path string
+		t    time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		t, err := time.ParseInLocation(rotatedTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{filepath.Join(filepath.Dir(r.path), name), t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, b := range backups {
+		if (r.maxBackups > 0 && i >= r.maxBackups) || (r.maxAge > 0 && b.t.Before(cutoff)) {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+// Reopen closes and reopens the log path, picking up a file moved away by logrotate.
+func (r *rotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil {
+		_ = r.f.Close()
+		r.f = nil
+	}
+	return r.open()
+}
+
+// Close closes the file and waits for pending compression.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.f != nil {
+		err = r.f.Close()
+		r.f = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	return err
+}
+
+// gzipFile compresses path to path.gz and removes the original.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFilePrunesAfterCompression(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "monitor.log")
+	r, err := newRotatingFile(path, 1, 2, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.maxSize = 64
+	line := []byte(strings.Repeat("x", 40) + "\n")
+	for i := 0; i < 50; i++ {
+		if _, err := r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backups []string
+	for _, e := range entries {
+		if e.Name() == "monitor.log" {
+			continue
+		}
+		if !strings.HasSuffix(e.Name(), ".log.gz") {
+			t.Errorf("left behind %s, want only compressed backups", e.Name())
+		}
+		backups = append(backups, e.Name())
+	}
+	if len(backups) != 2 {
+		t.Errorf("backups = %v, want the newest 2", backups)
+	}
+}
+
+func TestParseLogMode(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "stdout", true},
+		{"stdout", "stdout", true},
+		{" File ", "file", true},
+		{"BOTH", "both", true},
+		{"files", "", false},
+		{"stderr", "", false},
+		{"stdout,file", "", false},
+	} {
+		got, err := parseLogMode(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseLogMode(%q) = %q, %v; want %q (ok=%v)", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lokiPusher is an io.Writer placed next to stdout in the log tee. Every JSON
+// log line is parsed for its stream labels (selected fields such as level,
+// target, schema, msg plus static labels) and pushed to Loki's
+// /loki/api/v1/push in batches, flushed by size or age, optionally gzipped,
+// with retry and an X-Scope-OrgID tenant header.
+//
+// Write never blocks on the network and never logs (it runs under the log
+// handler's lock); delivery problems are logged from the flush loop.
+type lokiPusher struct {
+	client     *http.Client
+	url        string
+	tenant     string
+	labels     []string          // JSON fields promoted to stream labels
+	static     map[string]string // constant stream labels
+	batchBytes int
+	batchWait  time.Duration
+	maxBuffer  int
+	gzip       bool
+	retries    int
+
+	mu       sync.Mutex
+	log      *slog.Logger
+	partial  bytes.Buffer
+	streams  map[string]*lokiStream
+	buffered int
+	dropped  uint64
+	flushReq chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// newLokiPusher constructs the pusher. It buffers immediately but only starts
+// flushing once start is called with the logger used for its own diagnostics.
+func newLokiPusher(cfg Config) *lokiPusher {
+	p := &lokiPusher{
+		client:     &http.Client{Timeout: cfg.LokiTimeout()},
+		url:        cfg.LokiURL() + "/loki/api/v1/push",
+		tenant:     cfg.LokiTenant(),
+		labels:     cfg.LokiLabels(),
+		static:     cfg.LokiStaticLabels(),
+		batchBytes: cfg.LokiBatchBytes(),
+		batchWait:  cfg.LokiBatchWait(),
+		gzip:       cfg.LokiGzip(),
+		retries:    cfg.LokiRetries(),
+		streams:    make(map[string]*lokiStream),
+		flushReq:   make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if p.batchBytes <= 0 {
+		p.batchBytes = 1 << 20
+	}
+	if p.batchWait <= 0 {
+		p.batchWait = time.Second
+	}
+	// Keep at most a few batches while Loki is unreachable
+	p.maxBuffer = 8 * p.batchBytes
+	return p
+}
+
+func (p *lokiPusher) start(log *slog.Logger) {
+	p.mu.Lock()
+	p.log = log
+	p.mu.Unlock()
+	go p.flushLoop()
+}
+
+func (p *lokiPusher) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partial.Write(b)
+	for {
+		data := p.partial.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(data[:i]), "\r")
+		p.partial.Next(i + 1)
+		if line != "" {
+			p.add(line)
+		}
+	}
+	if p.buffered >= p.batchBytes {
+		select {
+		case p.flushReq <- struct{}{}:
+		default:
+		}
+	}
+	return len(b), nil
+}
+
+// add files one line under its stream. Lines that are not JSON objects get the static labels only.
+func (p *lokiPusher) add(line string) {
+	if p.buffered+len(line) > p.maxBuffer {
+		p.dropped++
+		return
+	}
+	var fields map[string]any
+	_ = json.Unmarshal([]byte(line), &fields)
+	ts := time.Now()
+	if s, ok := fields["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			ts = t
+		}
+	}
+	labels := make(map[string]string, len(p.static)+len(p.labels))
+	for k, v := range p.static {
+		labels[k] = v
+	}
+	for _, name := range p.labels {
+		v, ok := fields[name]
+		if !ok || v == nil {
+			continue
+		}
+		s := fmt.Sprint(v)
+		if s == "" {
+			continue
+		}
+		labels[name] = s
+	}
+	key := lokiStreamKey(labels)
+	st := p.streams[key]
+	if st == nil {
+		st = &lokiStream{Stream: labels}
+		p.streams[key] = st
+	}
+	st.Values = append(st.Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), line})
+	p.buffered += len(line)
+}
+
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+func (p *lokiPusher) flushLoop() {
+	defer close(p.done)
+	t := time.NewTicker(p.batchWait)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			p.flush()
+			return
+		case <-t.C:
+			p.flush()
+		case <-p.flushReq:
+			p.flush()
+		}
+	}
+}
+
+// flush pushes everything buffered so far; a batch that still fails after retries is dropped.
+func (p *lokiPusher) flush() {
+	p.mu.Lock()
+	streams, buffered, dropped, log := p.streams, p.buffered, p.dropped, p.log
+	p.streams, p.buffered, p.dropped = make(map[string]*lokiStream), 0, 0
+	p.mu.Unlock()
+
+	if dropped > 0 {
+		log.Warn("loki buffer full, dropped log lines", "lines", dropped)
+	}
+	if len(streams) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(streams))
+	for k := range streams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]*lokiStream, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, streams[k])
+	}
+	if err := p.send(map[string]any{"streams": list}); err != nil {
+		log.Warn("loki push failed", "streams", len(list), "bytes", buffered, "err", err)
+	}
+}
+
+// send POSTs the push request, retrying network errors, 429 and 5xx with exponential backoff.
+func (p *lokiPusher) send(payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	encoding := ""
+	if p.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body, encoding = buf.Bytes(), "gzip"
+	}
+	delay := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		retry, err := p.post(body, encoding)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= p.retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, 30*time.Second)
+	}
+}
+
+func (p *lokiPusher) post(body []byte, encoding string) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if p.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", p.tenant)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	default:
+		return false, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+}
+
+// Close pushes what is left and stops the flush loop.
+func (p *lokiPusher) Close() error {
+	close(p.stop)
+	<-p.done
+	return nil
+}
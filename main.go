@@ -15,17 +15,49 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
+	// Subcommands come first; the remaining arguments are regular flags
+	var cmd string
+	if len(os.Args) > 1 && (os.Args[1] == "top" || os.Args[1] == "healthcheck") {
+		cmd, os.Args = os.Args[1], append(os.Args[:1], os.Args[2:]...)
+	}
+	// healthcheck [live|ready] picks the probe (default ready)
+	live := false
+	if cmd == "healthcheck" && len(os.Args) > 1 && (os.Args[1] == "live" || os.Args[1] == "ready") {
+		live, os.Args = os.Args[1] == "live", append(os.Args[:1], os.Args[2:]...)
+	}
+
 	// Load configuration first so logging can honor level/mode settings
 	configuration := LoadConfig()
 
+	if cmd == "top" {
+		if err := runTop(configuration); err != nil {
+			fmt.Fprintln(os.Stderr, "top:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cmd == "healthcheck" {
+		if err := runHealthcheck(configuration, live); err != nil {
+			fmt.Fprintln(os.Stderr, "healthcheck:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Determine log level from config (default INFO)
 	lvl := slog.LevelInfo
 	switch strings.ToUpper(configuration.LogLevel()) {
@@ -39,9 +71,32 @@ func main() {
 		lvl = slog.LevelError
 	}
 
-	// Set up broadcaster and tee writer to mirror slog JSON lines to SSE (stdout only)
-	broadcaster := NewLogStreamBroadcaster()
-	tee := NewLogTeeWriter(os.Stdout, broadcaster)
+	// Log destination per MON_LOG_MODE: stdout, a rotating file, or both
+	var out io.Writer = os.Stdout
+	var logFile *rotatingFile
+	if configuration.LogMode() != "stdout" {
+		f, err := newRotatingFile(configuration.LogFile(), configuration.LogMaxSizeMB(), configuration.LogMaxBackups(), configuration.LogMaxAgeDays(), configuration.LogCompress())
+		if err != nil {
+			log.Fatalf("open log file: %v", err)
+		}
+		defer f.Close()
+		logFile = f
+		out = f
+		if configuration.LogMode() == "both" {
+			out = io.MultiWriter(os.Stdout, f)
+		}
+	}
+
+	// Set up broadcaster and tee writer to mirror slog JSON lines to SSE
+	globalLogRing = newLogRing(configuration.SSELogRing())
+	broadcaster := NewLogStreamBroadcaster(configuration)
+	// Optional direct push to Loki alongside stdout
+	var loki *lokiPusher
+	if configuration.LokiURL() != "" {
+		loki = newLokiPusher(configuration)
+		out = io.MultiWriter(out, loki)
+	}
+	tee := NewLogTeeWriter(out, broadcaster)
 
 	// Configure slog JSON for Loki-friendly fields and stable formatting
 	// - Keep time key as "time" with RFC3339Nano (default)
@@ -67,28 +122,137 @@ func main() {
 		"service", "monitor",
 		"host", host,
 		"pid", os.Getpid(),
+		"target", dsnTarget(configuration.DSN()),
 	)
+	if loki != nil {
+		loki.start(logger)
+		defer loki.Close()
+	}
+	redactor, err := NewRedactor(configuration.RedactMode(), configuration.RedactPatterns())
+	if err != nil {
+		logger.Error("redactor", "err", err)
+		os.Exit(1)
+	}
 
-	// HTTP server: frontpage and SSE logs
-	mux := http.NewServeMux()
-	// Frontpage for /
-	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_, _ = w.Write([]byte("<html><body><h1>Go to <a href=\"/logs\">/logs</a></h1></body></html>"))
-	})
-	// SSE endpoints for /logs and /logs/
-	mux.HandleFunc("/logs", LogsSSEHandler(broadcaster, logger))
-	mux.HandleFunc("/logs/", LogsSSEHandler(broadcaster, logger))
+	filter, err := NewDigestFilter(configuration, logger)
+	if err != nil {
+		logger.Error("ignore rules", "err", err)
+		os.Exit(1)
+	}
 
-	srv := &http.Server{Addr: ":8088", Handler: mux}
-	serverErr := make(chan error, 1)
+	// Runtime settings: MON_CONFIG_FILE applies on top of env at startup and on SIGHUP
+	settings := newConfigManager(configuration, filter, logger)
+	if configuration.ConfigFile() != "" {
+		if _, err := settings.Reload(flag.CommandLine, "file"); err != nil {
+			logger.Error("config file", "err", err)
+			os.Exit(1)
+		}
+	}
+	// SIGHUP reopens the log file (so external logrotate can move it away) and
+	// applies what changed in MON_CONFIG_FILE, if set
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 	go func() {
-		logger.Info("http server starting", "addr", srv.Addr, "endpoint", "/logs")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErr <- err
+		for range hup {
+			if logFile != nil {
+				if err := logFile.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "reopen log file: %v\n", err)
+				} else {
+					logger.Info("log file reopened", "path", configuration.LogFile())
+				}
+			}
+			if configuration.ConfigFile() == "" {
+				continue
+			}
+			changes, err := settings.Reload(flag.CommandLine, "sighup")
+			if err != nil {
+				logger.Error("config reload failed, keeping current settings", "err", err)
+				continue
+			}
+			logger.Info("config reloaded", "changes", len(changes))
 		}
 	}()
 
+	reporter, err := NewConfiguredReporter(configuration, logger)
+	if err != nil {
+		logger.Error("reporters", "err", err)
+		os.Exit(1)
+	}
+	metrics := newMetricsRegistry(configuration, reporter)
+	api := newAPIState(configuration)
+	events := newEventStream(configuration, logger)
+	silences, err := newSilenceStore(configuration, logger)
+	if err != nil {
+		logger.Error("silences", "err", err)
+		os.Exit(1)
+	}
+	observers := []intervalObserver{metrics, api, events, silences}
+	if configuration.EfficiencyWindow() > 0 {
+		observers = append(observers, newEfficiencyAnalyzer(configuration, logger))
+	}
+	if configuration.CatalogFile() != "" {
+		catalog, err := loadDigestCatalog(configuration, logger)
+		if err != nil {
+			logger.Error("digest catalog", "err", err)
+			os.Exit(1)
+		}
+		observers = append(observers, catalog)
+	}
+	if configuration.Budgets() != "" {
+		budgets, err := newBudgetTracker(configuration, logger)
+		if err != nil {
+			logger.Error("budgets", "err", err)
+			os.Exit(1)
+		}
+		observers = append(observers, budgets)
+	}
+	if len(configuration.SummaryPeriods()) > 0 {
+		summaries, err := newSummaryGenerator(configuration, logger, reporter)
+		if err != nil {
+			logger.Error("summary reports", "err", err)
+			os.Exit(1)
+		}
+		observers = append(observers, summaries)
+	}
+	if configuration.OTLPEndpoint() != "" {
+		observers = append(observers, newOTLPExporter(configuration, logger))
+	}
+	if configuration.StatsdAddr() != "" {
+		statsd, err := newStatsdEmitter(configuration, logger)
+		if err != nil {
+			logger.Error("statsd", "err", err)
+			os.Exit(1)
+		}
+		observers = append(observers, statsd)
+	}
+
+	// HTTP server: dashboard, SSE logs and events, metrics and JSON API
+	mux := http.NewServeMux()
+	// Embedded dashboard at /
+	mux.HandleFunc("GET /{$}", dashboardHandler())
+	// SSE endpoints for /logs and /logs/
+	mux.HandleFunc("/logs", LogsSSEHandler(configuration, broadcaster, logger))
+	mux.HandleFunc("/logs/", LogsSSEHandler(configuration, broadcaster, logger))
+
+	// Prometheus text exposition of throughput counters
+	mux.Handle("/metrics", metrics)
+
+	// JSON API over the state kept from recent intervals
+	api.register(mux)
+	settings.register(mux)
+	silences.register(mux)
+	// Typed monitor events (snapshot, offender, alert, ...) as SSE
+	mux.Handle("/events", events)
+
+	srv, err := newHTTPServer(configuration, mux, logger)
+	if err != nil {
+		logger.Error("http server", "err", err)
+		os.Exit(1)
+	}
+	if srv != nil {
+		srv.Start()
+	}
+
 	client, err := NewMySQLClient(configuration.DSN())
 	if err != nil {
 		logger.Error("open db", "err", err)
@@ -100,12 +264,26 @@ func main() {
 		}
 	}()
 
-	reporter := NewReporter(logger)
-	mon := NewMonitor(configuration, client, reporter, logger)
-	ctx := context.Background()
+	mon := NewMonitor(configuration, client, reporter, redactor, filter, logger, observers...)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if srv != nil {
+		// A dead listener would leave the endpoints unreachable; stop rather than run headless
+		go func() {
+			select {
+			case err := <-srv.Errors():
+				logger.Error("http server error, stopping", "err", err)
+				stop()
+			case <-ctx.Done():
+			}
+		}()
+	}
 	mon.Run(ctx)
 
 	// Graceful shutdown of HTTP server after monitor stops
+	if srv == nil {
+		return
+	}
 	shCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(shCtx); err != nil {
@@ -113,10 +291,4 @@ func main() {
 	} else {
 		logger.Info("http server stopped")
 	}
-
-	select {
-	case err := <-serverErr:
-		logger.Error("http server error", "err", err)
-	default:
-	}
 }
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsRegistry keeps cumulative counters fed by the monitor's interval
+// observer hooks and serves them at /metrics in the Prometheus text exposition
+// format (version 0.0.4). Per-digest series are limited to the top N digests by
+// estimated bytes to keep cardinality bounded; per-schema series cover the rest.
+// Digests idle for MON_METRICS_DIGEST_IDLE intervals are forgotten, so a
+// returning digest restarts its counters (a reset to Prometheus).
+type metricsRegistry struct {
+	mu         sync.Mutex
+	topN       int
+	idle       int // intervals
+	avgRowRead uint64
+	avgRowSent uint64
+	reporter   Reporter
+
+	digests map[snapKey]*throughputCounters
+	schemas map[string]*throughputCounters
+	engine  *engineIO
+
+	snapshots        uint64
+	snapshotErrors   uint64
+	snapshotSeconds  float64 // sum, for the summary
+	lastSnapshotSecs float64
+	lastSnapshotAt   time.Time
+	alerts           uint64
+	alertsSilenced   uint64
+	excluded         map[string]uint64 // executions per exclusion reason
+}
+
+type throughputCounters struct {
+	schema       string
+	digest       string
+	count        uint64
+	rowsExamined uint64
+	rowsSent     uint64
+	rowsAffected uint64
+	bytesRead    uint64
+	bytesWrite   uint64
+	lastSeen     time.Time
+}
+
+// sinkStatser is implemented by reporters that track per-sink delivery counters.
+type sinkStatser interface{ SinkStats() []sinkStats }
+
+func newMetricsRegistry(cfg Config, reporter Reporter) *metricsRegistry {
+	return &metricsRegistry{
+		topN:       cfg.MetricsTopDigests(),
+		idle:       cfg.MetricsDigestIdle(),
+		avgRowRead: cfg.AvgRowRead(),
+		avgRowSent: cfg.AvgRowSent(),
+		reporter:   reporter,
+		digests:    make(map[snapKey]*throughputCounters),
+		schemas:    make(map[string]*throughputCounters),
+		excluded:   make(map[string]uint64),
+	}
+}
+
+func (m *metricsRegistry) ObserveInterval(t tick) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots++
+	m.snapshotSeconds += t.Took.Seconds()
+	m.lastSnapshotSecs = t.Took.Seconds()
+	m.lastSnapshotAt = t.At
+	m.alerts += uint64(len(t.Alerts))
+	m.alertsSilenced += uint64(len(t.Silenced))
+	for reason, c := range t.Excluded {
+		m.excluded[reason] += c.Count
+	}
+	if t.Engine != nil {
+		e := t.Engine.Total
+		m.engine = &e
+	}
+	for k, d := range t.Delta {
+		dc := m.digests[k]
+		if dc == nil {
+			dc = &throughputCounters{schema: d.Schema, digest: d.Digest}
+			m.digests[k] = dc
+		}
+		dc.lastSeen = t.At
+		sc := m.schemas[d.Schema]
+		if sc == nil {
+			sc = &throughputCounters{schema: d.Schema}
+			m.schemas[d.Schema] = sc
+		}
+		for _, c := range []*throughputCounters{dc, sc} {
+			c.count += d.CountStar
+			c.rowsExamined += d.SumRowsExam
+			c.rowsSent += d.SumRowsSent
+			c.rowsAffected += d.SumRowsAff
+			c.bytesRead += d.SumRowsExam * m.avgRowRead
+			c.bytesWrite += d.SumRowsSent * m.avgRowSent
+		}
+	}
+	// Forget digests idle for longer than the idle window
+	if t.Elapsed > 0 && m.idle > 0 {
+		idle := time.Duration(m.idle) * t.Elapsed
+		for k, c := range m.digests {
+			if t.At.Sub(c.lastSeen) > idle {
+				delete(m.digests, k)
+			}
+		}
+	}
+}
+
+func (m *metricsRegistry) SnapshotFailed(time.Time, error) {
+	m.mu.Lock()
+	m.snapshotErrors++
+	m.mu.Unlock()
+}
+
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	_ = bw.Flush()
+}
+
+func (m *metricsRegistry) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Per-digest, top N by estimated bytes
+	top := make([]*throughputCounters, 0, len(m.digests))
+	for _, c := range m.digests {
+		top = append(top, c)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].bytesRead+top[i].bytesWrite > top[j].bytesRead+top[j].bytesWrite
+	})
+	if m.topN >= 0 && len(top) > m.topN {
+		top = top[:m.topN]
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].schema != top[j].schema {
+			return top[i].schema < top[j].schema
+		}
+		return top[i].digest < top[j].digest
+	})
+	schemas := make([]*throughputCounters, 0, len(m.schemas))
+	for _, c := range m.schemas {
+		schemas = append(schemas, c)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].schema < schemas[j].schema })
+
+	for _, fam := range []struct {
+		name, help string
+		value      func(*throughputCounters) uint64
+	}{
+		{"executions_total", "Statement executions.", func(c *throughputCounters) uint64 { return c.count }},
+		{"rows_examined_total", "Rows examined.", func(c *throughputCounters) uint64 { return c.rowsExamined }},
+		{"rows_sent_total", "Rows sent to clients.", func(c *throughputCounters) uint64 { return c.rowsSent }},
+		{"rows_affected_total", "Rows affected by DML.", func(c *throughputCounters) uint64 { return c.rowsAffected }},
+		{"estimated_read_bytes_total", "Estimated bytes read (rows examined x avg row bytes).", func(c *throughputCounters) uint64 { return c.bytesRead }},
+		{"estimated_write_bytes_total", "Estimated bytes written (rows sent x avg row bytes).", func(c *throughputCounters) uint64 { return c.bytesWrite }},
+	} {
+		name := "monitor_digest_" + fam.name
+		writeMetricHeader(w, name, fam.help+" Top digests only.", "counter")
+		for _, c := range top {
+			writeMetric(w, name, fam.value(c), "schema", c.schema, "digest", c.digest)
+		}
+		name = "monitor_schema_" + fam.name
+		writeMetricHeader(w, name, fam.help+" All digests per schema.", "counter")
+		for _, c := range schemas {
+			writeMetric(w, name, fam.value(c), "schema", c.schema)
+		}
+	}
+
+	if m.engine != nil {
+		writeMetricHeader(w, "monitor_engine_data_read_bytes_total", "InnoDB data read (Innodb_data_read).", "counter")
+		writeMetric(w, "monitor_engine_data_read_bytes_total", m.engine.Read)
+		writeMetricHeader(w, "monitor_engine_data_written_bytes_total", "InnoDB data written (Innodb_data_written).", "counter")
+		writeMetric(w, "monitor_engine_data_written_bytes_total", m.engine.Written)
+	}
+
+	writeMetricHeader(w, "monitor_snapshot_duration_seconds", "Duration of the performance_schema snapshot query.", "summary")
+	writeMetric(w, "monitor_snapshot_duration_seconds_sum", m.snapshotSeconds)
+	writeMetric(w, "monitor_snapshot_duration_seconds_count", m.snapshots)
+	writeMetricHeader(w, "monitor_snapshot_last_duration_seconds", "Duration of the most recent snapshot query.", "gauge")
+	writeMetric(w, "monitor_snapshot_last_duration_seconds", m.lastSnapshotSecs)
+	if !m.lastSnapshotAt.IsZero() {
+		writeMetricHeader(w, "monitor_snapshot_last_success_timestamp_seconds", "Unix time of the last successful snapshot.", "gauge")
+		writeMetric(w, "monitor_snapshot_last_success_timestamp_seconds", float64(m.lastSnapshotAt.UnixMilli())/1000)
+	}
+	writeMetricHeader(w, "monitor_snapshot_errors_total", "Failed snapshot queries.", "counter")
+	writeMetric(w, "monitor_snapshot_errors_total", m.snapshotErrors)
+	writeMetricHeader(w, "monitor_alerts_total", "Threshold alerts handed to reporters.", "counter")
+	writeMetric(w, "monitor_alerts_total", m.alerts)
+	writeMetricHeader(w, "monitor_alerts_silenced_total", "Threshold alerts muted by a silence instead of reported.", "counter")
+	writeMetric(w, "monitor_alerts_silenced_total", m.alertsSilenced)
+
+	writeMetricHeader(w, "monitor_excluded_executions_total", "Executions removed by ignore rules, by reason.", "counter")
+	reasons := make([]string, 0, len(m.excluded))
+	for r := range m.excluded {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	for _, r := range reasons {
+		writeMetric(w, "monitor_excluded_executions_total", m.excluded[r], "reason", r)
+	}
+
+	writeMetricHeader(w, "monitor_sse_subscribers", "Connected SSE clients.", "gauge")
+	writeMetric(w, "monitor_sse_subscribers", sseClients.Load())
+	writeMetricHeader(w, "monitor_sse_rejected_total", "SSE clients refused at MON_SSE_MAX_CLIENTS.", "counter")
+	writeMetric(w, "monitor_sse_rejected_total", sseRejected.Load())
+	writeMetricHeader(w, "monitor_sse_dropped_total", "Messages SSE clients lost by reading too slowly.", "counter")
+	writeMetric(w, "monitor_sse_dropped_total", sseDropped.Load())
+	writeMetricHeader(w, "monitor_sse_evicted_total", "SSE clients disconnected for staying behind past MON_SSE_SLOW_TIMEOUT.", "counter")
+	writeMetric(w, "monitor_sse_evicted_total", sseEvicted.Load())
+
+	if ss, ok := m.reporter.(sinkStatser); ok {
+		stats := ss.SinkStats()
+		for _, fam := range []struct {
+			name, help string
+			value      func(sinkStats) uint64
+		}{
+			{"monitor_reporter_delivered_total", "Reporter calls delivered, per sink.", func(s sinkStats) uint64 { return s.Delivered }},
+			{"monitor_reporter_failed_total", "Reporter calls that failed, per sink.", func(s sinkStats) uint64 { return s.Failed }},
+			{"monitor_reporter_dropped_total", "Reporter calls dropped on a full queue, per sink.", func(s sinkStats) uint64 { return s.Dropped }},
+			{"monitor_reporter_timeouts_total", "Reporter calls that timed out, per sink.", func(s sinkStats) uint64 { return s.TimedOut }},
+		} {
+			writeMetricHeader(w, fam.name, fam.help, "counter")
+			for _, s := range stats {
+				writeMetric(w, fam.name, fam.value(s), "sink", s.Name)
+			}
+		}
+	}
+}
+
+func writeMetricHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeMetric writes one sample; labels are given as alternating name/value pairs.
+func writeMetric[T uint64 | int64 | float64](w *bufio.Writer, name string, value T, labels ...string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i])
+			w.WriteString(`="`)
+			w.WriteString(escapeLabelValue(labels[i+1]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	switch v := any(value).(type) {
+	case float64:
+		w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case int64:
+		w.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		w.WriteString(strconv.FormatUint(v, 10))
+	}
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string { return labelEscaper.Replace(v) }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricsForgetsIdleDigests(t *testing.T) {
+	m := newMetricsRegistry(&config{metricsTopDigests: 10, metricsDigestIdle: 3, avgRowRead: 1, avgRowSent: 1}, nil)
+	busy, quiet := newSnapKey("shop", "busy"), newSnapKey("shop", "quiet")
+	names := map[snapKey]string{busy: "busy", quiet: "quiet"}
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	observe := func(keys ...snapKey) {
+		at = at.Add(10 * time.Second)
+		delta := make(map[snapKey]digestStat)
+		for _, k := range keys {
+			delta[k] = digestStat{Schema: "shop", Digest: names[k], CountStar: 1}
+		}
+		m.ObserveInterval(tick{At: at, Elapsed: 10 * time.Second, Delta: delta})
+	}
+
+	observe(busy, quiet)
+	for i := 0; i < 3; i++ {
+		observe(busy)
+	}
+	if _, ok := m.digests[quiet]; !ok {
+		t.Fatal("digest dropped before it was idle for 3 intervals")
+	}
+	observe(busy)
+	if _, ok := m.digests[quiet]; ok {
+		t.Error("digest idle for 4 intervals is still kept")
+	}
+	if c := m.digests[busy]; c == nil || c.count != 5 {
+		t.Errorf("busy digest = %+v, want 5 executions", c)
+	}
+	// Per-schema totals keep what the forgotten digest contributed
+	if c := m.schemas["shop"]; c.count != 6 {
+		t.Errorf("schema executions = %d, want 6", c.count)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -14,6 +15,7 @@ type Monitor interface{ Run(ctx context.Context) }
 
 // offender represents a per-interval delta with estimated bytes
 type offender struct {
+	Schema       string
 	Digest       string
 	Text         string
 	BytesRead    uint64
@@ -23,15 +25,65 @@ type offender struct {
 	Count        uint64
 }
 
+// tick is the per-interval result handed to every intervalObserver.
+type tick struct {
+	At       time.Time
+	Elapsed  time.Duration          // wall time covered by Delta (since the previous snapshot)
+	Took     time.Duration          // duration of the snapshot query
+	Delta    map[snapKey]digestStat // redacted per-digest deltas, ignore rules applied
+	Excluded exclusionStats         // traffic removed by ignore rules, per reason
+	Alerts   []offender             // offenders handed to the reporter this interval
+	Silenced []silencedAlert        // offenders over a threshold but muted by a silence
+	Resolved []offender             // previously firing offenders that have cleared
+	Engine   *engineSample          // InnoDB data I/O; nil unless RealIO is enabled and the query succeeded
+}
+
+// engineSample is the server-wide InnoDB data I/O at snapshot time and its change over the interval.
+type engineSample struct {
+	Total engineIO
+	Delta engineIO
+}
+
+// intervalObserver is notified after every successful snapshot.
+// Observers may also implement baselineObserver and/or io.Closer.
+type intervalObserver interface{ ObserveInterval(t tick) }
+
+// baselineObserver receives the (redacted) initial snapshot taken at startup.
+type baselineObserver interface {
+	ObserveBaseline(at time.Time, snap snapshot)
+}
+
+// failureObserver is notified when a periodic snapshot fails.
+type failureObserver interface {
+	SnapshotFailed(at time.Time, err error)
+}
+
 type monitor struct {
 	configuration Config
 	db            DBClient
 	reporter      Reporter
+	redactor      Redactor
+	filter        DigestFilter
 	log           *slog.Logger
+	observers     []intervalObserver
+	silencer      alertSilencer // optional, found among the observers
+	firing        map[snapKey]*firingAlert
 }
 
-func NewMonitor(configuration Config, db DBClient, r Reporter, log *slog.Logger) Monitor {
-	return &monitor{configuration: configuration, db: db, reporter: r, log: log}
+// firingAlert is an alert that has fired and not yet been resolved.
+type firingAlert struct {
+	o     offender
+	clear int // consecutive intervals below the thresholds
+}
+
+func NewMonitor(configuration Config, db DBClient, r Reporter, red Redactor, filter DigestFilter, log *slog.Logger, observers ...intervalObserver) Monitor {
+	m := &monitor{configuration: configuration, db: db, reporter: r, redactor: red, filter: filter, log: log, observers: observers, firing: make(map[snapKey]*firingAlert)}
+	for _, obs := range observers {
+		if s, ok := obs.(alertSilencer); ok {
+			m.silencer = s
+		}
+	}
+	return m
 }
 
 func (m *monitor) Run(ctx context.Context) {
@@ -52,12 +104,29 @@ func (m *monitor) Run(ctx context.Context) {
 		m.log.Error("initial snapshot failed", "err", err)
 		return
 	}
+	prevAt := time.Now()
+	prevEngine := m.engineIO(ctx)
+	m.filter.Refresh(ctx, m.db, prevAt)
+	if len(m.observers) > 0 {
+		base := make(snapshot, len(prev))
+		for k, d := range prev {
+			base[k] = d
+		}
+		m.filter.Filter(base)
+		redactStats(m.redactor, base)
+		for _, obs := range m.observers {
+			if bo, ok := obs.(baselineObserver); ok {
+				bo.ObserveBaseline(prevAt, base)
+			}
+		}
+	}
 
 	// graceful shutdown signals
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-	ticker := time.NewTicker(m.configuration.Interval())
+	interval := m.configuration.Interval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	var mu sync.Mutex
 
@@ -71,46 +140,182 @@ loop:
 			break loop
 		case <-ticker.C:
 			mu.Lock()
+			now := time.Now()
 			curr, err := m.db.Snapshot(ctx)
+			took := time.Since(now)
 			if err != nil {
 				m.log.Error("fetch snapshot", "err", err)
+				for _, obs := range m.observers {
+					if fo, ok := obs.(failureObserver); ok {
+						fo.SnapshotFailed(now, err)
+					}
+				}
 				mu.Unlock()
 				continue
 			}
 
 			delta := deltaSnap(prev, curr)
-			for _, d := range delta {
+			m.filter.Refresh(ctx, m.db, now)
+			excluded := m.filter.Filter(delta)
+			m.logExcluded(excluded)
+			// Scrub SQL text once, before any reporter or log line can see it
+			redactStats(m.redactor, delta)
+			var alerts []offender
+			var silenced []silencedAlert
+			var suspended []offender // firing alerts a silence now covers
+			fired := make(map[snapKey]offender)
+			for k, d := range delta {
 				br := d.SumRowsExam * m.configuration.AvgRowRead()
 				bw := d.SumRowsSent * m.configuration.AvgRowSent()
 				if br == 0 && bw == 0 {
 					continue
 				}
-				// Prefer real query sample when available (MySQL 8.0+), fall back to normalized DIGEST_TEXT
-				text := d.DigestText
-				if d.QuerySample.Valid && d.QuerySample.String != "" {
-					text = d.QuerySample.String
-				}
 				o := offender{
+					Schema:       d.Schema,
 					Digest:       d.Digest,
-					Text:         text,
+					Text:         sampleText(d),
 					BytesRead:    br,
 					BytesWrite:   bw,
 					RowsExamined: d.SumRowsExam,
 					RowsSent:     d.SumRowsSent,
 					Count:        d.CountStar,
 				}
-				if br >= m.configuration.ReadThreshold() || bw >= m.configuration.WriteThreshold() {
-					m.reporter.Alert(o, m.configuration.ReadThreshold(), m.configuration.WriteThreshold())
+				var rules []string
+				if br >= m.configuration.ReadThreshold() {
+					rules = append(rules, ruleRead)
+				}
+				if bw >= m.configuration.WriteThreshold() {
+					rules = append(rules, ruleWrite)
+				}
+				if len(rules) == 0 {
+					continue
+				}
+				if m.silencer != nil {
+					if id := m.silencer.Silenced(o, rules, now); id != "" {
+						silenced = append(silenced, silencedAlert{o, id})
+						// Reporters that keep firing alerts alive (Alertmanager resends)
+						// must stop too; it fires anew if still over once the silence ends
+						if f, ok := m.firing[k]; ok {
+							delete(m.firing, k)
+							suspended = append(suspended, f.o)
+						}
+						continue
+					}
+				}
+				m.reporter.Alert(o, m.configuration.ReadThreshold(), m.configuration.WriteThreshold())
+				alerts = append(alerts, o)
+				fired[k] = o
+			}
+			resolved := m.updateFiring(fired)
+			if ar, ok := m.reporter.(alertResolver); ok {
+				for _, o := range resolved {
+					ar.Resolve(o)
+				}
+				for _, o := range suspended {
+					ar.Resolve(o)
 				}
 			}
 
-			prev = curr
+			t := tick{At: now, Elapsed: now.Sub(prevAt), Took: took, Delta: delta, Excluded: excluded, Alerts: alerts, Silenced: silenced, Resolved: resolved}
+			if eng := m.engineIO(ctx); eng != nil {
+				t.Engine = &engineSample{Total: *eng}
+				if prevEngine != nil && eng.Read >= prevEngine.Read && eng.Written >= prevEngine.Written {
+					t.Engine.Delta = engineIO{Read: eng.Read - prevEngine.Read, Written: eng.Written - prevEngine.Written}
+				}
+				prevEngine = eng
+			}
+			for _, obs := range m.observers {
+				obs.ObserveInterval(t)
+			}
+
+			prev, prevAt = curr, now
+			// The interval may have been changed at runtime
+			if iv := m.configuration.Interval(); iv != interval {
+				interval = iv
+				ticker.Reset(iv)
+			}
 			mu.Unlock()
 		}
 	}
 
+	for _, obs := range m.observers {
+		if c, ok := obs.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				m.log.Error("observer close", "err", err)
+			}
+		}
+	}
 	if err := m.db.Close(); err != nil {
 		m.log.Error("db close", "err", err)
 	}
 	m.reporter.Shutdown()
 }
+
+// updateFiring records this interval's alerts and returns the firing alerts that
+// have now stayed below the thresholds for AlertResolveAfter consecutive intervals.
+func (m *monitor) updateFiring(fired map[snapKey]offender) []offender {
+	for k, o := range fired {
+		m.firing[k] = &firingAlert{o: o}
+	}
+	var resolved []offender
+	for k, f := range m.firing {
+		if _, ok := fired[k]; ok {
+			continue
+		}
+		f.clear++
+		if f.clear >= max(m.configuration.AlertResolveAfter(), 1) {
+			delete(m.firing, k)
+			resolved = append(resolved, f.o)
+		}
+	}
+	return resolved
+}
+
+// engineIO reads InnoDB data I/O totals when RealIO is enabled; nil otherwise or on error.
+func (m *monitor) engineIO(ctx context.Context) *engineIO {
+	if !m.configuration.RealIO() {
+		return nil
+	}
+	eio, err := m.db.EngineIO(ctx)
+	if err != nil {
+		m.log.Debug("engine io", "err", err)
+		return nil
+	}
+	return &eio
+}
+
+// logExcluded reports traffic removed by ignore rules. Self-exclusion alone is
+// expected every interval and only logged at DEBUG.
+func (m *monitor) logExcluded(ex exclusionStats) {
+	if len(ex) == 0 {
+		return
+	}
+	lvl := slog.LevelDebug
+	for reason := range ex {
+		if reason != excludeSelf {
+			lvl = slog.LevelInfo
+		}
+	}
+	total := ex.Total()
+	counts := make(map[string]uint64, len(ex))
+	for reason, c := range ex {
+		counts[reason] = c.Count
+	}
+	m.log.Log(context.Background(), lvl, "excluded traffic",
+		"digests", total.Digests,
+		"count", total.Count,
+		"rowsExamined", total.RowsExamined,
+		"rowsSent", total.RowsSent,
+		"rowsAffected", total.RowsAffected,
+		"countByReason", counts,
+	)
+}
+
+// sampleText prefers the real query sample when available (MySQL 8.0+) and
+// falls back to the normalized DIGEST_TEXT.
+func sampleText(d digestStat) string {
+	if d.QuerySample.Valid && d.QuerySample.String != "" {
+		return d.QuerySample.String
+	}
+	return d.DigestText
+}
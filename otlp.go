@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// otlpExporter sends per-interval digest metrics as OTLP metrics and alerts as
+// OTLP log records to an OpenTelemetry collector over OTLP/HTTP with JSON
+// encoding (/v1/metrics, /v1/logs). Data is buffered and flushed when the batch
+// size is reached or on the flush interval, with retry and exponential backoff
+// on transient failures.
+type otlpExporter struct {
+	log       *slog.Logger
+	client    *http.Client
+	endpoint  string
+	headers   map[string]string
+	resource  otlpResource
+	topN      int
+	batchSize int
+	retries   int
+	backoff   time.Duration // first retry delay, doubled per attempt
+	avgRead   uint64
+	avgSent   uint64
+
+	mu       sync.Mutex
+	metrics  map[string]*otlpMetric // pending data points by metric name
+	points   int
+	logs     []otlpLogRecord
+	flushReq chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// OTLP/JSON wire types (subset of opentelemetry-proto). 64-bit integers and
+// timestamps are encoded as strings as required by the protobuf JSON mapping.
+type (
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		AsInt             *string        `json:"asInt,omitempty"`
+		AsDouble          *float64       `json:"asDouble,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"` // 1 = delta, 2 = cumulative
+		IsMonotonic            bool            `json:"isMonotonic"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpMetric struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description,omitempty"`
+		Unit        string     `json:"unit,omitempty"`
+		Sum         *otlpSum   `json:"sum,omitempty"`
+		Gauge       *otlpGauge `json:"gauge,omitempty"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber"`
+		SeverityText   string         `json:"severityText"`
+		Body           otlpAnyValue   `json:"body"`
+		Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	}
+)
+
+const (
+	otlpScopeName     = "database-top-throughput-analyzer"
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpTemporalDelta = 1
+	otlpTemporalCumul = 2
+)
+
+func otlpString(k, v string) otlpKeyValue {
+	return otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func otlpInt(k string, v uint64) otlpKeyValue {
+	s := strconv.FormatUint(v, 10)
+	return otlpKeyValue{Key: k, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpTime(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+// newOTLPExporter constructs the exporter and starts its flush loop.
+func newOTLPExporter(cfg Config, log *slog.Logger) *otlpExporter {
+	host, _ := os.Hostname()
+	e := &otlpExporter{
+		log:       log,
+		client:    &http.Client{Timeout: cfg.OTLPTimeout()},
+		endpoint:  cfg.OTLPEndpoint(),
+		headers:   cfg.OTLPHeaders(),
+		topN:      cfg.MetricsTopDigests(),
+		batchSize: cfg.OTLPBatchSize(),
+		retries:   cfg.OTLPRetries(),
+		backoff:   500 * time.Millisecond,
+		avgRead:   cfg.AvgRowRead(),
+		avgSent:   cfg.AvgRowSent(),
+		resource: otlpResource{Attributes: []otlpKeyValue{
+			otlpString("service.name", cfg.OTLPServiceName()),
+			otlpString("host.name", host),
+			otlpString("db.system", "mysql"),
+			otlpString("monitor.target", dsnTarget(cfg.DSN())),
+		}},
+		metrics:  make(map[string]*otlpMetric),
+		flushReq: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = 1000
+	}
+	go e.flushLoop(cfg.OTLPFlushInterval())
+	return e
+}
+
+func (e *otlpExporter) ObserveInterval(t tick) {
+	start := otlpTime(t.At.Add(-t.Elapsed))
+	now := otlpTime(t.At)
+
+	// Top N digests by estimated bytes this interval
+	stats := make([]digestStat, 0, len(t.Delta))
+	for _, d := range t.Delta {
+		stats = append(stats, d)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].SumRowsExam*e.avgRead+stats[i].SumRowsSent*e.avgSent > stats[j].SumRowsExam*e.avgRead+stats[j].SumRowsSent*e.avgSent
+	})
+	if e.topN >= 0 && len(stats) > e.topN {
+		stats = stats[:e.topN]
+	}
+
+	e.mu.Lock()
+	for _, d := range stats {
+		attrs := []otlpKeyValue{otlpString("db.namespace", d.Schema), otlpString("db.query.digest", d.Digest)}
+		e.addDelta("db.query.executions", "{execution}", "Statement executions per interval.", attrs, start, now, d.CountStar)
+		e.addDelta("db.query.rows_examined", "{row}", "Rows examined per interval.", attrs, start, now, d.SumRowsExam)
+		e.addDelta("db.query.rows_sent", "{row}", "Rows sent per interval.", attrs, start, now, d.SumRowsSent)
+		e.addDelta("db.query.rows_affected", "{row}", "Rows affected per interval.", attrs, start, now, d.SumRowsAff)
+		e.addDelta("db.query.estimated_read", "By", "Estimated bytes read (rows examined x avg row bytes).", attrs, start, now, d.SumRowsExam*e.avgRead)
+		e.addDelta("db.query.estimated_write", "By", "Estimated bytes written (rows sent x avg row bytes).", attrs, start, now, d.SumRowsSent*e.avgSent)
+	}
+	if t.Engine != nil {
+		e.addCumulative("db.engine.data_read", "By", "InnoDB data read (Innodb_data_read).", now, t.Engine.Total.Read)
+		e.addCumulative("db.engine.data_written", "By", "InnoDB data written (Innodb_data_written).", now, t.Engine.Total.Written)
+	}
+	secs := t.Took.Seconds()
+	e.addPoint("monitor.snapshot.duration", "s", "Duration of the snapshot query.", nil, otlpDataPoint{TimeUnixNano: now, AsDouble: &secs})
+
+	for _, o := range t.Alerts {
+		e.logs = append(e.logs, otlpLogRecord{
+			TimeUnixNano:   now,
+			SeverityNumber: otlpSeverityWarn,
+			SeverityText:   "WARN",
+			Body:           otlpAnyValue{StringValue: ptr("ALERT: thresholds exceeded")},
+			Attributes: []otlpKeyValue{
+				otlpString("db.namespace", o.Schema),
+				otlpString("db.query.digest", o.Digest),
+				otlpString("db.query.text", o.Text),
+				otlpInt("monitor.bytes_read", o.BytesRead),
+				otlpInt("monitor.bytes_write", o.BytesWrite),
+				otlpInt("monitor.rows_examined", o.RowsExamined),
+				otlpInt("monitor.rows_sent", o.RowsSent),
+				otlpInt("monitor.count", o.Count),
+			},
+		})
+	}
+	for _, o := range t.Resolved {
+		e.logs = append(e.logs, otlpLogRecord{
+			TimeUnixNano:   now,
+			SeverityNumber: otlpSeverityInfo,
+			SeverityText:   "INFO",
+			Body:           otlpAnyValue{StringValue: ptr("RESOLVED: thresholds no longer exceeded")},
+			Attributes:     []otlpKeyValue{otlpString("db.namespace", o.Schema), otlpString("db.query.digest", o.Digest)},
+		})
+	}
+	full := e.points+len(e.logs) >= e.batchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushReq <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *otlpExporter) addDelta(name, unit, desc string, attrs []otlpKeyValue, start, now string, v uint64) {
+	s := strconv.FormatUint(v, 10)
+	m := e.metric(name, unit, desc, func(m *otlpMetric) {
+		m.Sum = &otlpSum{AggregationTemporality: otlpTemporalDelta, IsMonotonic: true}
+	})
+	m.Sum.DataPoints = append(m.Sum.DataPoints, otlpDataPoint{Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: now, AsInt: &s})
+	e.points++
+}
+
+func (e *otlpExporter) addCumulative(name, unit, desc, now string, v uint64) {
+	s := strconv.FormatUint(v, 10)
+	m := e.metric(name, unit, desc, func(m *otlpMetric) {
+		m.Sum = &otlpSum{AggregationTemporality: otlpTemporalCumul, IsMonotonic: true}
+	})
+	m.Sum.DataPoints = append(m.Sum.DataPoints, otlpDataPoint{TimeUnixNano: now, AsInt: &s})
+	e.points++
+}
+
+func (e *otlpExporter) addPoint(name, unit, desc string, attrs []otlpKeyValue, dp otlpDataPoint) {
+	m := e.metric(name, unit, desc, func(m *otlpMetric) { m.Gauge = &otlpGauge{} })
+	dp.Attributes = attrs
+	m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+	e.points++
+}
+
+// metric returns the pending metric by name, creating it with init on first use.
+func (e *otlpExporter) metric(name, unit, desc string, init func(*otlpMetric)) *otlpMetric {
+	m := e.metrics[name]
+	if m == nil {
+		m = &otlpMetric{Name: name, Unit: unit, Description: desc}
+		init(m)
+		e.metrics[name] = m
+	}
+	return m
+}
+
+func (e *otlpExporter) flushLoop(every time.Duration) {
+	defer close(e.done)
+	if every <= 0 {
+		every = 10 * time.Second
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-e.stop:
+			e.flush()
+			return
+		case <-t.C:
+			e.flush()
+		case <-e.flushReq:
+			e.flush()
+		}
+	}
+}
+
+// flush sends everything buffered so far; failed batches are dropped after retries.
+func (e *otlpExporter) flush() {
+	e.mu.Lock()
+	metrics, logs := e.metrics, e.logs
+	e.metrics, e.logs, e.points = make(map[string]*otlpMetric), nil, 0
+	e.mu.Unlock()
+
+	scope := otlpScope{Name: otlpScopeName}
+	if len(metrics) > 0 {
+		names := make([]string, 0, len(metrics))
+		for n := range metrics {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		list := make([]*otlpMetric, 0, len(names))
+		for _, n := range names {
+			list = append(list, metrics[n])
+		}
+		payload := map[string]any{"resourceMetrics": []any{map[string]any{
+			"resource":     e.resource,
+			"scopeMetrics": []any{map[string]any{"scope": scope, "metrics": list}},
+		}}}
+		if err := e.send("/v1/metrics", payload); err != nil {
+			e.log.Warn("otlp metrics export failed", "metrics", len(list), "err", err)
+		}
+	}
+	if len(logs) > 0 {
+		payload := map[string]any{"resourceLogs": []any{map[string]any{
+			"resource":  e.resource,
+			"scopeLogs": []any{map[string]any{"scope": scope, "logRecords": logs}},
+		}}}
+		if err := e.send("/v1/logs", payload); err != nil {
+			e.log.Warn("otlp logs export failed", "records", len(logs), "err", err)
+		}
+	}
+}
+
+// send POSTs payload, retrying network errors, 429 and 5xx with exponential
+// backoff. Retries also apply to the final flush on Close.
+func (e *otlpExporter) send(path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	delay := e.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := e.post(path, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= e.retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, 30*time.Second)
+	}
+}
+
+func (e *otlpExporter) post(path string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, e.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	default:
+		return false, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+}
+
+// Close flushes pending data and stops the flush loop.
+func (e *otlpExporter) Close() error {
+	close(e.stop)
+	<-e.done
+	return nil
+}
+
+func ptr[T any](v T) *T { return &v }
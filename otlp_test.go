@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestOTLP(t *testing.T, endpoint string, edit func(c *config)) *otlpExporter {
+	t.Helper()
+	c := testConfig(func(c *config) {
+		c.otlpEndpoint = endpoint
+		c.otlpHeaders = map[string]string{"X-Api-Key": "k1"}
+		if edit != nil {
+			edit(c)
+		}
+	})
+	e := newOTLPExporter(c, testLogger())
+	e.backoff = 5 * time.Millisecond
+	return e
+}
+
+var otlpTestAt = time.Date(2026, 10, 18, 12, 0, 0, 123456789, time.UTC)
+
+func otlpTestTick() tick {
+	return tick{
+		At:      otlpTestAt,
+		Elapsed: 10 * time.Second,
+		Took:    25 * time.Millisecond,
+		Delta: map[snapKey]digestStat{
+			newSnapKey("shop", "d1"): {Schema: "shop", Digest: "d1", CountStar: 5, SumRowsExam: 1000, SumRowsSent: 50, SumRowsAff: 2},
+			newSnapKey("crm", "d2"):  {Schema: "crm", Digest: "d2", CountStar: 1, SumRowsExam: 10, SumRowsSent: 1},
+		},
+		Alerts:   []offender{{Schema: "shop", Digest: "d1", Text: "SELECT 1", BytesRead: 100000, BytesWrite: 500, RowsExamined: 1000, RowsSent: 50, Count: 5}},
+		Resolved: []offender{{Schema: "crm", Digest: "d0"}},
+		Engine:   &engineSample{Total: engineIO{Read: 1 << 30, Written: 1 << 20}},
+	}
+}
+
+// OTLP/JSON shapes as a collector decodes them; int64 fields stay raw to check their encoding.
+type (
+	otlpTestValue struct {
+		StringValue *string         `json:"stringValue"`
+		IntValue    json.RawMessage `json:"intValue"`
+	}
+	otlpTestKV struct {
+		Key   string        `json:"key"`
+		Value otlpTestValue `json:"value"`
+	}
+	otlpTestResource struct {
+		Attributes []otlpTestKV `json:"attributes"`
+	}
+	otlpTestPoint struct {
+		Attributes        []otlpTestKV    `json:"attributes"`
+		StartTimeUnixNano json.RawMessage `json:"startTimeUnixNano"`
+		TimeUnixNano      json.RawMessage `json:"timeUnixNano"`
+		AsInt             json.RawMessage `json:"asInt"`
+		AsDouble          *float64        `json:"asDouble"`
+	}
+	otlpTestMetric struct {
+		Name string `json:"name"`
+		Unit string `json:"unit"`
+		Sum  *struct {
+			DataPoints             []otlpTestPoint `json:"dataPoints"`
+			AggregationTemporality int             `json:"aggregationTemporality"`
+			IsMonotonic            bool            `json:"isMonotonic"`
+		} `json:"sum"`
+		Gauge *struct {
+			DataPoints []otlpTestPoint `json:"dataPoints"`
+		} `json:"gauge"`
+	}
+	otlpTestMetrics struct {
+		ResourceMetrics []struct {
+			Resource     otlpTestResource `json:"resource"`
+			ScopeMetrics []struct {
+				Scope   otlpScope        `json:"scope"`
+				Metrics []otlpTestMetric `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	otlpTestLogs struct {
+		ResourceLogs []struct {
+			Resource  otlpTestResource `json:"resource"`
+			ScopeLogs []struct {
+				Scope      otlpScope `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano   json.RawMessage `json:"timeUnixNano"`
+					SeverityNumber int             `json:"severityNumber"`
+					SeverityText   string          `json:"severityText"`
+					Body           otlpTestValue   `json:"body"`
+					Attributes     []otlpTestKV    `json:"attributes"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+)
+
+func otlpAttrs(kvs []otlpTestKV) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		switch {
+		case kv.Value.StringValue != nil:
+			m[kv.Key] = *kv.Value.StringValue
+		case kv.Value.IntValue != nil:
+			m[kv.Key] = string(kv.Value.IntValue)
+		}
+	}
+	return m
+}
+
+// quoted is v as a JSON string, the protobuf JSON mapping of 64-bit integers.
+func quoted(v int64) string { return strconv.Quote(strconv.FormatInt(v, 10)) }
+
+func checkOTLPResource(t *testing.T, r otlpTestResource) {
+	t.Helper()
+	attrs := otlpAttrs(r.Attributes)
+	for k, want := range map[string]string{"service.name": "mysql-monitor", "db.system": "mysql", "monitor.target": "db.internal:3306"} {
+		if attrs[k] != want {
+			t.Errorf("resource attribute %s = %q, want %q", k, attrs[k], want)
+		}
+	}
+	if attrs["host.name"] == "" {
+		t.Error("resource attribute host.name missing")
+	}
+}
+
+func TestOTLPMetricsPayload(t *testing.T) {
+	col := newHTTPRecorder(t)
+	e := newTestOTLP(t, col.URL(), nil)
+	e.ObserveInterval(otlpTestTick())
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exports := col.byPath("/v1/metrics")
+	if len(exports) != 1 {
+		t.Fatalf("got %d metric exports, want 1", len(exports))
+	}
+	if ct := exports[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if k := exports[0].Header.Get("X-Api-Key"); k != "k1" {
+		t.Errorf("configured header X-Api-Key = %q, want k1", k)
+	}
+	var p otlpTestMetrics
+	if err := json.Unmarshal(exports[0].Body, &p); err != nil {
+		t.Fatalf("invalid payload: %v\n%s", err, exports[0].Body)
+	}
+	if len(p.ResourceMetrics) != 1 || len(p.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("unexpected payload shape: %s", exports[0].Body)
+	}
+	checkOTLPResource(t, p.ResourceMetrics[0].Resource)
+	sm := p.ResourceMetrics[0].ScopeMetrics[0]
+	if sm.Scope.Name != otlpScopeName {
+		t.Errorf("scope = %q", sm.Scope.Name)
+	}
+	metrics := make(map[string]otlpTestMetric)
+	for _, m := range sm.Metrics {
+		metrics[m.Name] = m
+	}
+
+	exec, ok := metrics["db.query.executions"]
+	if !ok || exec.Sum == nil || exec.Sum.AggregationTemporality != otlpTemporalDelta || !exec.Sum.IsMonotonic {
+		t.Fatalf("db.query.executions = %+v, want a monotonic delta sum", exec)
+	}
+	if len(exec.Sum.DataPoints) != 2 {
+		t.Fatalf("got %d executions points, want 2", len(exec.Sum.DataPoints))
+	}
+	for _, dp := range exec.Sum.DataPoints {
+		if got, want := string(dp.TimeUnixNano), quoted(otlpTestAt.UnixNano()); got != want {
+			t.Errorf("timeUnixNano = %s, want string %s", got, want)
+		}
+		if got, want := string(dp.StartTimeUnixNano), quoted(otlpTestAt.Add(-10*time.Second).UnixNano()); got != want {
+			t.Errorf("startTimeUnixNano = %s, want string %s", got, want)
+		}
+		attrs := otlpAttrs(dp.Attributes)
+		want := map[string]string{"d1": `"5"`, "d2": `"1"`}[attrs["db.query.digest"]]
+		if string(dp.AsInt) != want {
+			t.Errorf("executions for %v = %s, want %s", attrs, dp.AsInt, want)
+		}
+	}
+	if read := metrics["db.query.estimated_read"]; read.Unit != "By" || read.Sum == nil || string(read.Sum.DataPoints[0].AsInt) == "" {
+		t.Errorf("db.query.estimated_read = %+v", read)
+	}
+	eng := metrics["db.engine.data_read"]
+	if eng.Sum == nil || eng.Sum.AggregationTemporality != otlpTemporalCumul || string(eng.Sum.DataPoints[0].AsInt) != quoted(1<<30) {
+		t.Errorf("db.engine.data_read = %+v, want cumulative %s", eng, quoted(1<<30))
+	}
+	dur := metrics["monitor.snapshot.duration"]
+	if dur.Gauge == nil || dur.Gauge.DataPoints[0].AsDouble == nil || *dur.Gauge.DataPoints[0].AsDouble != 0.025 {
+		t.Errorf("monitor.snapshot.duration = %+v, want gauge 0.025", dur)
+	}
+}
+
+func TestOTLPLogsPayload(t *testing.T) {
+	col := newHTTPRecorder(t)
+	e := newTestOTLP(t, col.URL(), nil)
+	e.ObserveInterval(otlpTestTick())
+	_ = e.Close()
+
+	exports := col.byPath("/v1/logs")
+	if len(exports) != 1 {
+		t.Fatalf("got %d log exports, want 1", len(exports))
+	}
+	var p otlpTestLogs
+	if err := json.Unmarshal(exports[0].Body, &p); err != nil {
+		t.Fatalf("invalid payload: %v\n%s", err, exports[0].Body)
+	}
+	checkOTLPResource(t, p.ResourceLogs[0].Resource)
+	records := p.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want alert and resolution", len(records))
+	}
+	alert, resolved := records[0], records[1]
+	if alert.SeverityNumber != otlpSeverityWarn || alert.SeverityText != "WARN" || string(alert.TimeUnixNano) != quoted(otlpTestAt.UnixNano()) {
+		t.Errorf("alert record = %+v", alert)
+	}
+	attrs := otlpAttrs(alert.Attributes)
+	if attrs["db.query.digest"] != "d1" || attrs["db.query.text"] != "SELECT 1" || attrs["monitor.bytes_read"] != `"100000"` {
+		t.Errorf("alert attributes = %v (ints must be strings)", attrs)
+	}
+	if resolved.SeverityNumber != otlpSeverityInfo || otlpAttrs(resolved.Attributes)["db.query.digest"] != "d0" {
+		t.Errorf("resolution record = %+v", resolved)
+	}
+}
+
+func TestOTLPFlushesWhenBatchIsFull(t *testing.T) {
+	col := newHTTPRecorder(t)
+	// One digest yields 6 points plus the snapshot duration
+	e := newTestOTLP(t, col.URL(), func(c *config) { c.otlpBatchSize = 5 })
+	defer e.Close()
+	tk := otlpTestTick()
+	tk.Alerts, tk.Resolved, tk.Engine = nil, nil, nil
+	e.ObserveInterval(tk)
+	col.wait(t, "/v1/metrics", time.Second)
+	if n := len(col.byPath("/v1/logs")); n != 0 {
+		t.Errorf("got %d log exports without log records", n)
+	}
+}
+
+func TestOTLPFlushesOnInterval(t *testing.T) {
+	col := newHTTPRecorder(t)
+	e := newTestOTLP(t, col.URL(), func(c *config) { c.otlpFlushInterval = 50 * time.Millisecond })
+	defer e.Close()
+	e.ObserveInterval(otlpTestTick())
+	col.wait(t, "/v1/metrics", time.Second)
+	col.wait(t, "/v1/logs", time.Second)
+
+	// Nothing buffered: later ticks of the flush loop send nothing
+	time.Sleep(150 * time.Millisecond)
+	if n := len(col.byPath("/v1/metrics")); n != 1 {
+		t.Errorf("got %d metric exports, want 1", n)
+	}
+}
+
+func TestOTLPRetriesServerErrors(t *testing.T) {
+	col := newHTTPRecorder(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	e := newTestOTLP(t, col.URL(), func(c *config) { c.otlpRetries = 3 })
+	tk := otlpTestTick()
+	tk.Alerts, tk.Resolved = nil, nil
+	e.ObserveInterval(tk)
+	_ = e.Close()
+
+	exports := col.byPath("/v1/metrics")
+	if len(exports) != 3 {
+		t.Fatalf("got %d attempts, want 3 (two 5xx, then success)", len(exports))
+	}
+	for _, ex := range exports[1:] {
+		if string(ex.Body) != string(exports[0].Body) {
+			t.Error("retry sent a different payload")
+		}
+	}
+}
+
+func TestOTLPDoesNotRetryClientErrors(t *testing.T) {
+	col := newHTTPRecorder(t, http.StatusBadRequest, http.StatusBadRequest)
+	e := newTestOTLP(t, col.URL(), func(c *config) { c.otlpRetries = 3 })
+	tk := otlpTestTick()
+	tk.Alerts, tk.Resolved = nil, nil
+	e.ObserveInterval(tk)
+	_ = e.Close()
+	if n := len(col.byPath("/v1/metrics")); n != 1 {
+		t.Errorf("got %d attempts, want 1", n)
+	}
+}
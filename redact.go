@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redactor scrubs SQL text before it is handed to reporters or written to the
+// log stream (stdout, SSE ring, Loki). Modes:
+//   - mask:   string and numeric literals are replaced with '?' (default)
+//   - digest: the text is dropped entirely, only the digest is kept
+//   - off:    text is passed through untouched
+//
+// Custom regex redactors run on top of off/mask and replace matches with [REDACTED].
+type Redactor interface {
+	Redact(digest, text string) string
+}
+
+const (
+	redactModeOff    = "off"
+	redactModeMask   = "mask"
+	redactModeDigest = "digest"
+
+	redactedMarker = "[REDACTED]"
+)
+
+// sqlRedactor is the hidden implementation of Redactor.
+type sqlRedactor struct {
+	mode     string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor constructs a Redactor for the given mode and extra regex patterns.
+func NewRedactor(mode string, patterns []string) (Redactor, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		mode = redactModeMask
+	case redactModeOff, redactModeMask, redactModeDigest:
+	default:
+		return nil, fmt.Errorf("unknown redact mode %q (want off|mask|digest)", mode)
+	}
+	r := &sqlRedactor{mode: mode}
+	for _, p := range patterns {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *sqlRedactor) Redact(digest, text string) string {
+	switch r.mode {
+	case redactModeDigest:
+		return "digest:" + digest
+	case redactModeMask:
+		text = maskSQLLiterals(text)
+	}
+	for _, re := range r.patterns {
+		text = re.ReplaceAllString(text, redactedMarker)
+	}
+	return text
+}
+
+// redactStats scrubs the SQL text of every entry in place.
+func redactStats(r Redactor, stats map[snapKey]digestStat) {
+	for k, d := range stats {
+		d.DigestText = r.Redact(d.Digest, d.DigestText)
+		if d.QuerySample.Valid {
+			d.QuerySample.String = r.Redact(d.Digest, d.QuerySample.String)
+		}
+		stats[k] = d
+	}
+}
+
+// maskSQLLiterals tokenizes a MySQL statement and replaces string, hex/bit and
+// numeric literals with '?'. Identifiers (plain and `quoted`), keywords,
+// operators and comments are kept as-is.
+func maskSQLLiterals(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	n := len(s)
+	for i := 0; i < n; {
+		c := s[i]
+		switch {
+		case c == '\'' || c == '"':
+			i = skipQuoted(s, i, c)
+			b.WriteByte('?')
+		case c == '`':
+			j := skipQuoted(s, i, '`')
+			b.WriteString(s[i:j])
+			i = j
+		case c == '#' || (c == '-' && i+2 < n && s[i+1] == '-' && isSpaceByte(s[i+2])):
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				j = n - i
+			}
+			b.WriteString(s[i : i+j])
+			i += j
+		case c == '/' && i+1 < n && s[i+1] == '*':
+			j := strings.Index(s[i+2:], "*/")
+			if j < 0 {
+				j = n
+			} else {
+				j = i + 2 + j + 2
+			}
+			b.WriteString(s[i:j])
+			i = j
+		case isDigitByte(c) || (c == '.' && i+1 < n && isDigitByte(s[i+1]) && (i == 0 || !isIdentByte(s[i-1]))):
+			j := skipNumber(s, i)
+			if j < n && isIdentByte(s[j]) {
+				// identifiers may start with digits (e.g. 1st_table)
+				for j < n && isIdentByte(s[j]) {
+					j++
+				}
+				b.WriteString(s[i:j])
+			} else {
+				b.WriteByte('?')
+			}
+			i = j
+		case isIdentByte(c):
+			j := i
+			for j < n && isIdentByte(s[j]) {
+				j++
+			}
+			// X'..', B'..' and N'..' literals
+			if j-i == 1 && j < n && s[j] == '\'' && strings.ContainsRune("xXbBnN", rune(c)) {
+				i = skipQuoted(s, j, '\'')
+				b.WriteByte('?')
+				continue
+			}
+			b.WriteString(s[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// skipQuoted returns the index just past the quoted token starting at s[i],
+// honoring backslash escapes and doubled quote characters.
+func skipQuoted(s string, i int, q byte) int {
+	n := len(s)
+	for j := i + 1; j < n; j++ {
+		switch s[j] {
+		case '\\':
+			if q != '`' {
+				j++
+			}
+		case q:
+			if j+1 < n && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return n
+}
+
+// skipNumber returns the index just past the numeric literal starting at s[i].
+func skipNumber(s string, i int) int {
+	n := len(s)
+	if i+1 < n && s[i] == '0' && (s[i+1] == 'x' || s[i+1] == 'X' || s[i+1] == 'b' || s[i+1] == 'B') {
+		j := i + 2
+		for j < n && isHexByte(s[j]) {
+			j++
+		}
+		if j > i+2 {
+			return j
+		}
+	}
+	j := i
+	for j < n && isDigitByte(s[j]) {
+		j++
+	}
+	if j < n && s[j] == '.' {
+		j++
+		for j < n && isDigitByte(s[j]) {
+			j++
+		}
+	}
+	if j < n && (s[j] == 'e' || s[j] == 'E') {
+		k := j + 1
+		if k < n && (s[k] == '+' || s[k] == '-') {
+			k++
+		}
+		if k < n && isDigitByte(s[k]) {
+			for k < n && isDigitByte(s[k]) {
+				k++
+			}
+			j = k
+		}
+	}
+	return j
+}
+
+func isDigitByte(c byte) bool { return c >= '0' && c <= '9' }
+func isSpaceByte(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isHexByte(c byte) bool {
+	return isDigitByte(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || isDigitByte(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
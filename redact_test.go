@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestMaskSQLLiterals(t *testing.T) {
+	for _, tc := range []struct{ name, in, want string }{
+		{"strings", `SELECT * FROM users WHERE email = 'ana@example.com' AND name = "Ana"`,
+			`SELECT * FROM users WHERE email = ? AND name = ?`},
+		{"backslash escaped quote", `SELECT 'it\'s a secret', 'x'`, `SELECT ?, ?`},
+		{"doubled quote", `SELECT 'it''s', "say ""hi"""`, `SELECT ?, ?`},
+		{"escaped backslash before closing quote", `SELECT 'C:\\' , 'pw'`, `SELECT ? , ?`},
+		{"hex string", `SELECT X'4D7953514C', x'ff'`, `SELECT ?, ?`},
+		{"bit and national strings", `SELECT b'0101', N'naïve'`, `SELECT ?, ?`},
+		{"hex and bit numbers", `SELECT 0x1F, 0XAB, 0b101`, `SELECT ?, ?, ?`},
+		{"integers and decimals", `LIMIT 10 OFFSET 20 WHERE p > 3.14 AND q < .5`, `LIMIT ? OFFSET ? WHERE p > ? AND q < ?`},
+		{"exponents", `SELECT 1e10, 2.5E-3, 6e+2`, `SELECT ?, ?, ?`},
+		{"signed numbers keep the operator", `SELECT -5, a-1`, `SELECT -?, a-?`},
+		{"in list", `WHERE id IN (1, 2, 3)`, `WHERE id IN (?, ?, ?)`},
+		{"identifiers with digits", `SELECT c1, t2.col3, 1st_table.x FROM db1.t2`, `SELECT c1, t2.col3, 1st_table.x FROM db1.t2`},
+		{"backtick identifiers", "SELECT `order`, `a``b`, `it's` FROM `t 1`", "SELECT `order`, `a``b`, `it's` FROM `t 1`"},
+		{"backtick with backslash", "SELECT `a\\` FROM t WHERE x = 'v'", "SELECT `a\\` FROM t WHERE x = ?"},
+		{"block comment", `SELECT /* hint 'x' 42 */ a FROM t WHERE b = 7`, `SELECT /* hint 'x' 42 */ a FROM t WHERE b = ?`},
+		{"optimizer hint", `SELECT /*+ MAX_EXECUTION_TIME(1000) */ 1`, `SELECT /*+ MAX_EXECUTION_TIME(1000) */ ?`},
+		{"line comments", "SELECT 1 -- note 'x'\nFROM t # 5\nWHERE a = 2", "SELECT ? -- note 'x'\nFROM t # 5\nWHERE a = ?"},
+		{"double dash without space is not a comment", `SELECT 5--3`, `SELECT ?--?`},
+		{"unterminated string", `SELECT 'abc`, `SELECT ?`},
+		{"unterminated comment", `SELECT 1 /* open`, `SELECT ? /* open`},
+		{"placeholders untouched", `SELECT ? FROM t WHERE a = ?`, `SELECT ? FROM t WHERE a = ?`},
+		{"multibyte identifiers", `SELECT größe FROM maße WHERE wert = 'ü'`, `SELECT größe FROM maße WHERE wert = ?`},
+		{"empty", ``, ``},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := maskSQLLiterals(tc.in); got != tc.want {
+				t.Errorf("maskSQLLiterals(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRedactorModes(t *testing.T) {
+	const sql = `SELECT * FROM users WHERE email = 'ana@example.com' AND token = 'tok_123'`
+	for _, tc := range []struct {
+		mode     string
+		patterns []string
+		want     string
+	}{
+		{"", nil, `SELECT * FROM users WHERE email = ? AND token = ?`},
+		{"mask", nil, `SELECT * FROM users WHERE email = ? AND token = ?`},
+		{"digest", nil, "digest:abc"},
+		{"off", nil, sql},
+		{"off", []string{`tok_[0-9]+`}, `SELECT * FROM users WHERE email = 'ana@example.com' AND token = '[REDACTED]'`},
+		{"mask", []string{`users`}, `SELECT * FROM [REDACTED] WHERE email = ? AND token = ?`},
+	} {
+		r, err := NewRedactor(tc.mode, tc.patterns)
+		if err != nil {
+			t.Fatalf("mode %q: %v", tc.mode, err)
+		}
+		if got := r.Redact("abc", sql); got != tc.want {
+			t.Errorf("mode %q patterns %v: got %q, want %q", tc.mode, tc.patterns, got, tc.want)
+		}
+	}
+	if _, err := NewRedactor("partial", nil); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
@@ -7,10 +7,16 @@ import (
 // Reporter abstracts how results are reported (slimmed)
 type Reporter interface {
 	Startup(configuration Config)
-	Alert(o offender, readThreshold, writeThreshold uint64) // always logs full sample
+	Alert(o offender, readThreshold, writeThreshold uint64) // logs the sample as passed (already redacted by the monitor)
 	Shutdown()
 }
 
+// alertResolver is implemented by reporters that want to know when a firing
+// alert has cleared. o is the last offender that fired for the digest.
+type alertResolver interface {
+	Resolve(o offender)
+}
+
 // logReporter hides implementation behind Reporter
 type logReporter struct{ log *slog.Logger }
 
@@ -24,12 +30,14 @@ func (r *logReporter) Startup(cfg Config) {
 		"writeThreshold", bytesToHuman(cfg.WriteThreshold()),
 		"avgRowRead", cfg.AvgRowRead(),
 		"avgRowSent", cfg.AvgRowSent(),
+		"redact", cfg.RedactMode(),
 	)
 }
 
 func (r *logReporter) Alert(o offender, readThreshold, writeThreshold uint64) {
 	r.log.Warn("ALERT: thresholds exceeded",
 		"digest", o.Digest,
+		"schema", o.Schema,
 		"readThreshold", bytesToHuman(readThreshold),
 		"writeThreshold", bytesToHuman(writeThreshold),
 		"actualRead", bytesToHuman(o.BytesRead),
@@ -37,7 +45,16 @@ func (r *logReporter) Alert(o offender, readThreshold, writeThreshold uint64) {
 		"actualRowsExamined", o.RowsExamined,
 		"actualRowsSent", o.RowsSent,
 		"count", o.Count,
-		"sample", o.Text, // full, untrimmed sample
+		"sample", o.Text, // untrimmed sample, redacted per configuration
+	)
+}
+
+func (r *logReporter) Resolve(o offender) {
+	r.log.Info("RESOLVED: thresholds no longer exceeded",
+		"digest", o.Digest,
+		"schema", o.Schema,
+		"lastRead", bytesToHuman(o.BytesRead),
+		"lastWrite", bytesToHuman(o.BytesWrite),
 	)
 }
 
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// amAlert is one entry of an Alertmanager API v2 postableAlerts payload.
+type amAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// alertmanagerReporter posts alerts to Alertmanager's /api/v2/alerts, one per
+// exceeded threshold (rule read or write) of a digest. Firing alerts are
+// re-sent every resend interval with an endsAt a few intervals in the future
+// so Alertmanager keeps them active; a resolution (endsAt = now) is sent for
+// all of a digest's alerts when the monitor reports that it has cleared.
+type alertmanagerReporter struct {
+	log      *slog.Logger
+	client   *http.Client
+	url      string
+	resend   time.Duration
+	severity string
+	static   map[string]string
+	target   string
+
+	mu     sync.Mutex
+	active map[amKey]*amAlert
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// amKey identifies an alert the way its labels do: digest, schema and rule.
+type amKey struct {
+	key  snapKey
+	rule string
+}
+
+const amAlertName = "MySQLQueryThroughputExceeded"
+
+// NewAlertmanagerReporter constructs the Alertmanager Reporter from cfg.
+func NewAlertmanagerReporter(cfg Config, log *slog.Logger) (Reporter, error) {
+	if cfg.AlertmanagerURL() == "" {
+		return nil, errors.New("alertmanager reporter enabled but MON_ALERTMANAGER_URL is empty")
+	}
+	a := &alertmanagerReporter{
+		log:      log,
+		client:   &http.Client{Timeout: cfg.AlertmanagerTimeout()},
+		url:      cfg.AlertmanagerURL() + "/api/v2/alerts",
+		resend:   cfg.AlertmanagerResend(),
+		severity: cfg.AlertmanagerSeverity(),
+		static:   cfg.AlertmanagerLabels(),
+		target:   dsnTarget(cfg.DSN()),
+		active:   make(map[amKey]*amAlert),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if a.resend <= 0 {
+		a.resend = time.Minute
+	}
+	go a.resendLoop()
+	return a, nil
+}
+
+func (a *alertmanagerReporter) Startup(Config) {}
+
+func (a *alertmanagerReporter) Alert(o offender, readThreshold, writeThreshold uint64) {
+	if err := a.SendAlert(context.Background(), o, readThreshold, writeThreshold); err != nil {
+		a.log.Warn("alertmanager delivery failed", "err", err)
+	}
+}
+
+// SendAlert fires or refreshes an alert for each threshold o exceeds. An alert
+// for a threshold that is no longer exceeded keeps firing until the digest is
+// resolved, so it does not flap while the other one stays over.
+func (a *alertmanagerReporter) SendAlert(ctx context.Context, o offender, readThreshold, writeThreshold uint64) error {
+	now := time.Now().UTC()
+	key := newSnapKey(o.Schema, o.Digest)
+	var payload []amAlert
+	a.mu.Lock()
+	for _, rule := range amRules(o, readThreshold, writeThreshold) {
+		al, ok := a.active[amKey{key, rule}]
+		if !ok {
+			al = &amAlert{StartsAt: now}
+			a.active[amKey{key, rule}] = al
+		}
+		al.Labels = a.labels(o, rule)
+		al.Annotations = map[string]string{
+			"summary":        fmt.Sprintf("Query %s on %s exceeded the %s throughput threshold", shortDigest(o.Digest), a.target, rule),
+			"sample":         o.Text,
+			"actualRead":     bytesToHuman(o.BytesRead),
+			"actualWrite":    bytesToHuman(o.BytesWrite),
+			"readThreshold":  bytesToHuman(readThreshold),
+			"writeThreshold": bytesToHuman(writeThreshold),
+			"rowsExamined":   strconv.FormatUint(o.RowsExamined, 10),
+			"rowsSent":       strconv.FormatUint(o.RowsSent, 10),
+			"count":          strconv.FormatUint(o.Count, 10),
+		}
+		al.EndsAt = now.Add(3 * a.resend)
+		payload = append(payload, *al)
+	}
+	a.mu.Unlock()
+	if len(payload) == 0 {
+		return nil
+	}
+	return a.post(ctx, payload)
+}
+
+func (a *alertmanagerReporter) Resolve(o offender) {
+	if err := a.SendResolve(context.Background(), o); err != nil {
+		a.log.Warn("alertmanager resolve failed", "err", err)
+	}
+}
+
+// SendResolve resolves every alert of the digest, whichever rules fired.
+func (a *alertmanagerReporter) SendResolve(ctx context.Context, o offender) error {
+	key := newSnapKey(o.Schema, o.Digest)
+	now := time.Now().UTC()
+	var payload []amAlert
+	a.mu.Lock()
+	for _, rule := range []string{ruleRead, ruleWrite} {
+		if al, ok := a.active[amKey{key, rule}]; ok {
+			delete(a.active, amKey{key, rule})
+			p := *al
+			p.EndsAt = now
+			payload = append(payload, p)
+		}
+	}
+	a.mu.Unlock()
+	if len(payload) == 0 {
+		return nil
+	}
+	return a.post(ctx, payload)
+}
+
+// labels identify the alert, so they stay the same while it fires; rule uses
+// the same values as silence matchers (read, write).
+func (a *alertmanagerReporter) labels(o offender, rule string) map[string]string {
+	l := make(map[string]string, len(a.static)+6)
+	for k, v := range a.static {
+		l[k] = v
+	}
+	l["alertname"] = amAlertName
+	l["digest"] = o.Digest
+	l["schema"] = o.Schema
+	l["rule"] = rule
+	l["severity"] = a.severity
+	l["target"] = a.target
+	return l
+}
+
+// amRules lists the thresholds the offender exceeded.
+func amRules(o offender, readThreshold, writeThreshold uint64) []string {
+	var rules []string
+	if o.BytesRead >= readThreshold {
+		rules = append(rules, ruleRead)
+	}
+	if o.BytesWrite >= writeThreshold {
+		rules = append(rules, ruleWrite)
+	}
+	return rules
+}
+
+// resendLoop keeps firing alerts alive in Alertmanager until they are resolved.
+func (a *alertmanagerReporter) resendLoop() {
+	defer close(a.done)
+	t := time.NewTicker(a.resend)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-t.C:
+			now := time.Now().UTC()
+			a.mu.Lock()
+			batch := make([]amAlert, 0, len(a.active))
+			for _, al := range a.active {
+				al.EndsAt = now.Add(3 * a.resend)
+				batch = append(batch, *al)
+			}
+			a.mu.Unlock()
+			if len(batch) == 0 {
+				continue
+			}
+			sort.Slice(batch, func(i, j int) bool { return batch[i].StartsAt.Before(batch[j].StartsAt) })
+			if err := a.post(context.Background(), batch); err != nil {
+				a.log.Warn("alertmanager resend failed", "alerts", len(batch), "err", err)
+			}
+		}
+	}
+}
+
+func (a *alertmanagerReporter) post(ctx context.Context, alerts []amAlert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alertmanager: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Shutdown stops re-sending. Active alerts are left to expire at their endsAt
+// rather than resolved, since the monitor going away says nothing about the query.
+func (a *alertmanagerReporter) Shutdown() {
+	close(a.stop)
+	<-a.done
+}
+
+func shortDigest(d string) string {
+	if len(d) > 12 {
+		return d[:12]
+	}
+	return d
+}
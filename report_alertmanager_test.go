@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerOneAlertPerRule(t *testing.T) {
+	sink := newHTTPRecorder(t)
+	rep, err := NewAlertmanagerReporter(testConfig(func(c *config) { c.alertmanagerURL = sink.URL() }), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := rep.(*alertmanagerReporter)
+	defer a.Shutdown()
+	last := func() map[string]amAlert {
+		reqs := sink.requests()
+		var batch []amAlert
+		if err := json.Unmarshal(reqs[len(reqs)-1].Body, &batch); err != nil {
+			t.Fatalf("invalid payload: %v", err)
+		}
+		out := make(map[string]amAlert)
+		for _, al := range batch {
+			out[al.Labels["rule"]] = al
+		}
+		return out
+	}
+	ctx := context.Background()
+	o := offender{Schema: "shop", Digest: "d1", Text: "SELECT 1", BytesRead: 200, BytesWrite: 5}
+
+	if err := a.SendAlert(ctx, o, 100, 100); err != nil {
+		t.Fatal(err)
+	}
+	got := last()
+	read, ok := got[ruleRead]
+	if len(got) != 1 || !ok {
+		t.Fatalf("read over threshold: got alerts %v, want one with rule=read", got)
+	}
+	if l := read.Labels; l["alertname"] != amAlertName || l["digest"] != "d1" || l["schema"] != "shop" || l["severity"] != "warning" {
+		t.Errorf("labels = %v", l)
+	}
+	if _, ok := read.Annotations["rule"]; ok {
+		t.Error("rule is a label, not an annotation")
+	}
+
+	// Now over both: the read alert keeps its identity and start, a write alert is added
+	o.BytesWrite = 300
+	if err := a.SendAlert(ctx, o, 100, 100); err != nil {
+		t.Fatal(err)
+	}
+	got = last()
+	if len(got) != 2 || got[ruleWrite].Labels["rule"] != ruleWrite {
+		t.Fatalf("over both: got alerts %v, want read and write", got)
+	}
+	if !got[ruleRead].StartsAt.Equal(read.StartsAt) {
+		t.Errorf("read alert restarted: %v, was %v", got[ruleRead].StartsAt, read.StartsAt)
+	}
+
+	if err := a.SendResolve(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	got = last()
+	if len(got) != 2 {
+		t.Fatalf("resolve: got alerts %v, want both rules", got)
+	}
+	for rule, al := range got {
+		if al.EndsAt.After(time.Now()) {
+			t.Errorf("%s alert not resolved: endsAt %v", rule, al.EndsAt)
+		}
+	}
+	if len(a.active) != 0 {
+		t.Errorf("%d alerts still active after resolve", len(a.active))
+	}
+}
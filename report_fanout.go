@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// alertSender is implemented by reporters that can tell whether an alert was
+// actually delivered. The fan-out prefers it over Alert so that per-sink
+// delivery errors are counted and logged, and cancels ctx after MON_REPORTER_TIMEOUT.
+type alertSender interface {
+	SendAlert(ctx context.Context, o offender, readThreshold, writeThreshold uint64) error
+}
+
+// resolveSender is the error-returning counterpart of alertResolver.
+type resolveSender interface {
+	SendResolve(ctx context.Context, o offender) error
+}
+
+// summarySender is the error-returning counterpart of summaryReporter.
+type summarySender interface {
+	SendSummary(ctx context.Context, r *summaryReport) error
+}
+
+// namedReporter pairs a sink with the name it was configured under.
+type namedReporter struct {
+	name string
+	r    Reporter
+}
+
+// fanoutReporter dispatches Startup/Alert/Shutdown to several child reporters
+// concurrently. Each child has its own bounded queue and worker, so a slow or
+// failing sink only delays (or drops) its own deliveries.
+type fanoutReporter struct {
+	log     *slog.Logger
+	sinks   []*fanoutSink
+	timeout time.Duration
+
+	mu     sync.RWMutex // held for writing while the queues are closed
+	closed bool
+}
+
+type fanoutSink struct {
+	name  string
+	r     Reporter
+	queue chan fanoutCall
+	done  chan struct{}
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+	timedOut  atomic.Uint64
+}
+
+type fanoutCall struct {
+	op string
+	fn func(ctx context.Context) error
+}
+
+// sinkStats is a point-in-time view of one sink's delivery counters.
+type sinkStats struct {
+	Name      string
+	Delivered uint64
+	Failed    uint64
+	Dropped   uint64
+	TimedOut  uint64
+}
+
+// NewFanoutReporter constructs a Reporter that drives all sinks at once.
+func NewFanoutReporter(log *slog.Logger, sinks []namedReporter, queueSize int, timeout time.Duration) Reporter {
+	if queueSize <= 0 {
+		queueSize = 256
+	}
+	f := &fanoutReporter{log: log, timeout: timeout}
+	for _, s := range sinks {
+		fs := &fanoutSink{name: s.name, r: s.r, queue: make(chan fanoutCall, queueSize), done: make(chan struct{})}
+		f.sinks = append(f.sinks, fs)
+		go f.worker(fs)
+	}
+	return f
+}
+
+// NewConfiguredReporter builds the reporters listed in cfg.Reporters(). A lone
+// "log" reporter is returned as-is; anything else is wrapped in a fan-out.
+func NewConfiguredReporter(cfg Config, log *slog.Logger) (Reporter, error) {
+	var sinks []namedReporter
+	for _, name := range cfg.Reporters() {
+		var r Reporter
+		switch strings.ToLower(name) {
+		case "log":
+			r = NewReporter(log)
+		case "webhook":
+			wr, err := NewWebhookReporter(cfg, log)
+			if err != nil {
+				return nil, err
+			}
+			if worst := wr.(*webhookReporter).worstCase(); cfg.ReporterTimeout() > 0 && worst > cfg.ReporterTimeout() {
+				log.Warn("webhook retries can outlast MON_REPORTER_TIMEOUT; slow deliveries are cut short and spooled",
+					"worstCase", worst.String(), "reporterTimeout", cfg.ReporterTimeout().String())
+			}
+			r = wr
+		case "alertmanager":
+			ar, err := NewAlertmanagerReporter(cfg, log)
+			if err != nil {
+				return nil, err
+			}
+			r = ar
+		default:
+			return nil, fmt.Errorf("unknown reporter %q", name)
+		}
+		sinks = append(sinks, namedReporter{name: strings.ToLower(name), r: r})
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("no reporters configured")
+	}
+	if len(sinks) == 1 && sinks[0].name == "log" {
+		return sinks[0].r, nil
+	}
+	return NewFanoutReporter(log, sinks, cfg.ReporterQueue(), cfg.ReporterTimeout()), nil
+}
+
+func (f *fanoutReporter) Startup(cfg Config) {
+	f.dispatch("startup", func(_ context.Context, r Reporter) error { r.Startup(cfg); return nil })
+}
+
+func (f *fanoutReporter) Alert(o offender, readThreshold, writeThreshold uint64) {
+	f.dispatch("alert", func(ctx context.Context, r Reporter) error {
+		if s, ok := r.(alertSender); ok {
+			return s.SendAlert(ctx, o, readThreshold, writeThreshold)
+		}
+		r.Alert(o, readThreshold, writeThreshold)
+		return nil
+	})
+}
+
+// Resolve forwards resolutions to the sinks that implement alertResolver.
+func (f *fanoutReporter) Resolve(o offender) {
+	for _, s := range f.sinks {
+		ar, ok := s.r.(alertResolver)
+		if !ok {
+			continue
+		}
+		f.enqueue(s, "resolve", func(ctx context.Context) error {
+			if rs, ok := ar.(resolveSender); ok {
+				return rs.SendResolve(ctx, o)
+			}
+			ar.Resolve(o)
+			return nil
+		})
+	}
+}
+
+// Summary forwards a finished summary report to the sinks that implement summaryReporter.
+func (f *fanoutReporter) Summary(r *summaryReport) {
+	for _, s := range f.sinks {
+		sr, ok := s.r.(summaryReporter)
+		if !ok {
+			continue
+		}
+		f.enqueue(s, "summary", func(ctx context.Context) error {
+			if ss, ok := sr.(summarySender); ok {
+				return ss.SendSummary(ctx, r)
+			}
+			sr.Summary(r)
+			return nil
+		})
+	}
+}
+
+// Shutdown forwards Shutdown to every sink and waits (bounded by the timeout)
+// for their queues to drain. Calls that arrive afterwards are dropped.
+func (f *fanoutReporter) Shutdown() {
+	f.dispatch("shutdown", func(_ context.Context, r Reporter) error { r.Shutdown(); return nil })
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	for _, s := range f.sinks {
+		close(s.queue)
+	}
+	f.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, s := range f.sinks {
+		wg.Add(1)
+		go func(s *fanoutSink) {
+			defer wg.Done()
+			select {
+			case <-s.done:
+			case <-time.After(f.timeout):
+				f.log.Warn("reporter did not drain before shutdown", "sink", s.name, "pending", len(s.queue))
+			}
+		}(s)
+	}
+	wg.Wait()
+	for _, st := range f.SinkStats() {
+		f.log.Info("reporter delivery totals",
+			"sink", st.Name,
+			"delivered", st.Delivered,
+			"failed", st.Failed,
+			"dropped", st.Dropped,
+			"timedOut", st.TimedOut,
+		)
+	}
+}
+
+// SinkStats returns delivery counters per sink.
+func (f *fanoutReporter) SinkStats() []sinkStats {
+	out := make([]sinkStats, 0, len(f.sinks))
+	for _, s := range f.sinks {
+		out = append(out, sinkStats{
+			Name:      s.name,
+			Delivered: s.delivered.Load(),
+			Failed:    s.failed.Load(),
+			Dropped:   s.dropped.Load(),
+			TimedOut:  s.timedOut.Load(),
+		})
+	}
+	return out
+}
+
+// dispatch enqueues op on every sink without blocking; a full queue drops the call.
+func (f *fanoutReporter) dispatch(op string, call func(context.Context, Reporter) error) {
+	for _, s := range f.sinks {
+		r := s.r
+		f.enqueue(s, op, func(ctx context.Context) error { return call(ctx, r) })
+	}
+}
+
+func (f *fanoutReporter) enqueue(s *fanoutSink, op string, fn func(context.Context) error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		s.dropped.Add(1)
+		f.log.Warn("reporter shut down, dropping", "sink", s.name, "op", op)
+		return
+	}
+	select {
+	case s.queue <- fanoutCall{op: op, fn: fn}:
+	default:
+		s.dropped.Add(1)
+		f.log.Warn("reporter queue full, dropping", "sink", s.name, "op", op)
+	}
+}
+
+func (f *fanoutReporter) worker(s *fanoutSink) {
+	defer close(s.done)
+	for c := range s.queue {
+		err := f.run(c)
+		switch {
+		case err == nil:
+			s.delivered.Add(1)
+		case errors.Is(err, errSinkTimeout):
+			s.timedOut.Add(1)
+			f.log.Warn("reporter delivery timed out", "sink", s.name, "op", c.op, "timeout", f.timeout.String())
+		default:
+			s.failed.Add(1)
+			f.log.Warn("reporter delivery failed", "sink", s.name, "op", c.op, "err", err)
+		}
+	}
+}
+
+var errSinkTimeout = errors.New("reporter timed out")
+
+// run executes one call, converting panics to errors. The call's context is
+// cancelled after the timeout; sinks return once it is, so a slow sink holds up
+// only its own queue. A call that failed after its context expired counts as
+// timed out, one that still succeeded as delivered.
+func (f *fanoutReporter) run(c fanoutCall) (err error) {
+	ctx := context.Background()
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	if err = c.fn(ctx); err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", errSinkTimeout, err)
+	}
+	return err
+}
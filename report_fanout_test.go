@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// The fan-out only sees delivery errors and cancels slow deliveries through these.
+var (
+	_ alertSender   = (*webhookReporter)(nil)
+	_ summarySender = (*webhookReporter)(nil)
+	_ alertSender   = (*alertmanagerReporter)(nil)
+	_ resolveSender = (*alertmanagerReporter)(nil)
+)
+
+// ctxSink is a sink whose SendAlert blocks until delay passes or ctx is done.
+type ctxSink struct {
+	delay     time.Duration
+	cancelled chan struct{}
+}
+
+func (s *ctxSink) Startup(Config)                 {}
+func (s *ctxSink) Alert(offender, uint64, uint64) {}
+func (s *ctxSink) Shutdown()                      {}
+
+func (s *ctxSink) SendAlert(ctx context.Context, _ offender, _, _ uint64) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		s.cancelled <- struct{}{}
+		return ctx.Err()
+	}
+}
+
+func TestFanoutCancelsTimedOutDeliveries(t *testing.T) {
+	slow := &ctxSink{delay: time.Hour, cancelled: make(chan struct{}, 16)}
+	fast := &ctxSink{delay: 0, cancelled: make(chan struct{}, 16)}
+	f := NewFanoutReporter(testLogger(),
+		[]namedReporter{{"slow", slow}, {"fast", fast}}, 16, 20*time.Millisecond).(*fanoutReporter)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		f.Alert(testOffender, 1, 1)
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case <-slow.cancelled:
+		case <-time.After(time.Second):
+			t.Fatalf("delivery %d was not cancelled at the timeout", i+1)
+		}
+	}
+	f.Shutdown()
+
+	stats := map[string]sinkStats{}
+	for _, st := range f.SinkStats() {
+		stats[st.Name] = st
+	}
+	// Shutdown is delivered to both sinks as well
+	if st := stats["slow"]; st.TimedOut != 5 || st.Delivered != 1 || st.Failed != 0 {
+		t.Errorf("slow sink stats = %+v, want 5 alerts timed out", st)
+	}
+	if st := stats["fast"]; st.Delivered != 6 || st.TimedOut != 0 {
+		t.Errorf("fast sink stats = %+v, want 5 alerts delivered", st)
+	}
+	// Cancelled deliveries must not leave goroutines behind
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines grew from %d to %d", before, after)
+	}
+}
+
+func TestFanoutRunClassifiesErrors(t *testing.T) {
+	f := &fanoutReporter{timeout: 20 * time.Millisecond}
+	if err := f.run(fanoutCall{fn: func(context.Context) error { return errors.New("boom") }}); err == nil || errors.Is(err, errSinkTimeout) {
+		t.Errorf("plain failure = %v, want a non-timeout error", err)
+	}
+	if err := f.run(fanoutCall{fn: func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }}); !errors.Is(err, errSinkTimeout) {
+		t.Errorf("cancelled call = %v, want errSinkTimeout", err)
+	}
+	late := func(context.Context) error { time.Sleep(30 * time.Millisecond); return nil }
+	if err := f.run(fanoutCall{fn: late}); err != nil {
+		t.Errorf("late success = %v, want delivered", err)
+	}
+	if err := f.run(fanoutCall{fn: func(context.Context) error { panic("oops") }}); err == nil {
+		t.Error("panic not converted to an error")
+	}
+}
+
+func TestFanoutDropsCallsAfterShutdown(t *testing.T) {
+	sink := &ctxSink{cancelled: make(chan struct{}, 1)}
+	f := NewFanoutReporter(testLogger(), []namedReporter{{"sink", sink}}, 16, time.Second).(*fanoutReporter)
+	f.Shutdown()
+
+	// None of these may send on the closed queue
+	f.Alert(testOffender, 1, 1)
+	f.Resolve(testOffender)
+	f.Summary(&summaryReport{})
+	f.Shutdown()
+
+	st := f.SinkStats()[0]
+	if st.Delivered != 1 {
+		t.Errorf("delivered = %d, want only the first shutdown", st.Delivered)
+	}
+	if st.Dropped != 2 {
+		t.Errorf("dropped = %d, want the alert and the second shutdown", st.Dropped)
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Built-in payload templates, selected via MON_WEBHOOK_TEMPLATE.
+// Any other value is read as a path to a text/template file.
+var webhookTemplates = map[string]string{
+	"json": `{"event":"alert","time":{{json .Time}},"host":{{json .Host}},"digest":{{json .Digest}},"schema":{{json .Schema}},` +
+		`"count":{{.Count}},"rowsExamined":{{.RowsExamined}},"rowsSent":{{.RowsSent}},` +
+		`"actualRead":{{json .BytesRead}},"actualWrite":{{json .BytesWrite}},` +
+		`"readThreshold":{{json .ReadThreshold}},"writeThreshold":{{json .WriteThreshold}},"sample":{{json .Sample}}}`,
+	"slack": `{"text":{{json (printf "MySQL throughput alert on %s: read %s / write %s (thresholds %s / %s)" .Host .BytesRead .BytesWrite .ReadThreshold .WriteThreshold)}},` +
+		`"blocks":[` +
+		`{"type":"header","text":{"type":"plain_text","text":"MySQL throughput alert"}},` +
+		`{"type":"section","fields":[` +
+		`{"type":"mrkdwn","text":{{json (printf "*Host*\n%s" .Host)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Schema*\n%s" .Schema)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Read*\n%s (≥ %s)" .BytesRead .ReadThreshold)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Write*\n%s (≥ %s)" .BytesWrite .WriteThreshold)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Executions*\n%d" .Count)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Digest*\n%s" .Digest)}}}]},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":{{json (printf "` + "```%s```" + `" (trim .Sample 2800))}}}}]}`,
+	"teams": `{"@type":"MessageCard","@context":"https://schema.org/extensions","themeColor":"D9534F",` +
+		`"summary":{{json (printf "MySQL throughput alert on %s" .Host)}},"title":"MySQL throughput alert",` +
+		`"sections":[{"facts":[` +
+		`{"name":"Host","value":{{json .Host}}},{"name":"Schema","value":{{json .Schema}}},` +
+		`{"name":"Read","value":{{json (printf "%s (threshold %s)" .BytesRead .ReadThreshold)}}},` +
+		`{"name":"Write","value":{{json (printf "%s (threshold %s)" .BytesWrite .WriteThreshold)}}},` +
+		`{"name":"Executions","value":{{json (printf "%d" .Count)}}},{"name":"Digest","value":{{json .Digest}}}],` +
+		`"text":{{json (printf "<pre>%s</pre>" (html (trim .Sample 4000)))}}}]}`,
+}
+
+// Built-in summary templates, used with the alert template of the same name.
+// Custom template files only render alerts; summaries are not sent with them.
+var webhookSummaryTemplates = map[string]string{
+	"json": `{"event":"summary","host":{{json .Host}},"target":{{json .Target}},"period":{{json .Period}},` +
+		`"start":{{json .Start}},"end":{{json .End}},"intervals":{{.Intervals}},"total":{{json .Total}},` +
+		`"schemas":{{json .Schemas}},"statements":{{json .Statements}},"top":{{json .Top}},"files":{{json .Files}}}`,
+	"slack": `{"text":{{json (printf "MySQL throughput %s summary for %s: %d executions, read %s / write %s" .Period .Target .Total.Count .BytesRead .BytesWrite)}},` +
+		`"blocks":[` +
+		`{"type":"header","text":{"type":"plain_text","text":{{json (printf "MySQL throughput %s summary" .Period)}}}},` +
+		`{"type":"section","fields":[` +
+		`{"type":"mrkdwn","text":{{json (printf "*Target*\n%s" .Target)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Period*\n%s – %s" .Start .End)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Executions*\n%d" .Total.Count)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Digests*\n%d" .Total.Digests)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Read*\n%s" .BytesRead)}}},` +
+		`{"type":"mrkdwn","text":{{json (printf "*Write*\n%s" .BytesWrite)}}}]},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":{{json (printf "*Top digests*\n` + "```%s```" + `" (trim .TopText 2800))}}}}]}`,
+	"teams": `{"@type":"MessageCard","@context":"https://schema.org/extensions","themeColor":"0076D7",` +
+		`"summary":{{json (printf "MySQL throughput %s summary for %s" .Period .Target)}},"title":{{json (printf "MySQL throughput %s summary" .Period)}},` +
+		`"sections":[{"facts":[` +
+		`{"name":"Target","value":{{json .Target}}},{"name":"Period","value":{{json (printf "%s – %s" .Start .End)}}},` +
+		`{"name":"Executions","value":{{json (printf "%d" .Total.Count)}}},{"name":"Digests","value":{{json (printf "%d" .Total.Digests)}}},` +
+		`{"name":"Read","value":{{json .BytesRead}}},{"name":"Write","value":{{json .BytesWrite}}}],` +
+		`"text":{{json (printf "<pre>%s</pre>" (html (trim .TopText 4000)))}}}]}`,
+}
+
+// webhookAlert is the data made available to payload templates.
+type webhookAlert struct {
+	Time           string
+	Host           string
+	Digest         string
+	Schema         string
+	Sample         string
+	Count          uint64
+	RowsExamined   uint64
+	RowsSent       uint64
+	BytesRead      string
+	BytesWrite     string
+	ReadThreshold  string
+	WriteThreshold string
+}
+
+// webhookSummary is the data made available to summary templates.
+type webhookSummary struct {
+	Host       string
+	Target     string
+	Period     string
+	Start      string
+	End        string
+	Intervals  int
+	Total      summaryTotals
+	Schemas    []summaryTotals
+	Statements []summaryTotals
+	Top        []summaryDigest
+	Files      []string
+	BytesRead  string // Total, human-readable
+	BytesWrite string
+	TopText    string // one line per top digest
+}
+
+// spooledRequest is one undelivered POST kept on disk until the endpoint recovers.
+type spooledRequest struct {
+	URL  string `json:"url"`
+	Body string `json:"body"`
+}
+
+// webhookReporter POSTs alerts to one or more URLs using a text/template payload.
+// Failed deliveries are retried with exponential backoff and then spooled to
+// disk (bounded) to be replayed once the endpoint is reachable again.
+type webhookReporter struct {
+	log         *slog.Logger
+	client      *http.Client
+	urls        []string
+	tmpl        *template.Template
+	summaryTmpl *template.Template // nil with a custom alert template
+	contentType string
+	secret      []byte
+	retries     int
+	backoff     time.Duration
+	spoolDir    string
+	spoolMax    int
+	host        string
+
+	spoolMu sync.Mutex
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+const (
+	webhookMaxBackoff    = 30 * time.Second
+	webhookReplayEvery   = 30 * time.Second
+	webhookSignatureHdr  = "X-Monitor-Signature"
+	webhookTimestampHdr  = "X-Monitor-Timestamp"
+	webhookSignaturePref = "sha256="
+)
+
+// NewWebhookReporter constructs the webhook Reporter from cfg.
+func NewWebhookReporter(cfg Config, log *slog.Logger) (Reporter, error) {
+	if len(cfg.WebhookURLs()) == 0 {
+		return nil, errors.New("webhook reporter enabled but MON_WEBHOOK_URLS is empty")
+	}
+	name := strings.ToLower(cfg.WebhookTemplate())
+	src, ok := webhookTemplates[name]
+	if !ok {
+		data, err := os.ReadFile(cfg.WebhookTemplate())
+		if err != nil {
+			return nil, fmt.Errorf("webhook template: %w", err)
+		}
+		src = string(data)
+	}
+	tmpl, err := parseWebhookTemplate(src)
+	if err != nil {
+		return nil, fmt.Errorf("webhook template: %w", err)
+	}
+	var summaryTmpl *template.Template
+	if src, ok := webhookSummaryTemplates[name]; ok {
+		if summaryTmpl, err = parseWebhookTemplate(src); err != nil {
+			return nil, fmt.Errorf("webhook summary template: %w", err)
+		}
+	}
+	host, _ := os.Hostname()
+	w := &webhookReporter{
+		log:         log,
+		client:      &http.Client{Timeout: cfg.WebhookTimeout()},
+		urls:        cfg.WebhookURLs(),
+		tmpl:        tmpl,
+		summaryTmpl: summaryTmpl,
+		contentType: cfg.WebhookContentType(),
+		secret:      []byte(cfg.WebhookSecret()),
+		retries:     cfg.WebhookRetries(),
+		backoff:     500 * time.Millisecond,
+		spoolDir:    cfg.WebhookSpoolDir(),
+		spoolMax:    cfg.WebhookSpoolMax(),
+		host:        host,
+		stop:        make(chan struct{}),
+	}
+	if w.spoolDir != "" {
+		if err := os.MkdirAll(w.spoolDir, 0o755); err != nil {
+			return nil, fmt.Errorf("webhook spool: %w", err)
+		}
+		w.stopped.Add(1)
+		go w.replayLoop()
+	}
+	return w, nil
+}
+
+func parseWebhookTemplate(src string) (*template.Template, error) {
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"trim": func(s string, n int) string { return trimString(s, n) },
+	}).Parse(src)
+}
+
+func (w *webhookReporter) Startup(Config) {}
+
+func (w *webhookReporter) Alert(o offender, readThreshold, writeThreshold uint64) {
+	if err := w.SendAlert(context.Background(), o, readThreshold, writeThreshold); err != nil {
+		w.log.Warn("webhook delivery failed", "err", err)
+	}
+}
+
+func (w *webhookReporter) SendAlert(ctx context.Context, o offender, readThreshold, writeThreshold uint64) error {
+	var buf bytes.Buffer
+	err := w.tmpl.Execute(&buf, webhookAlert{
+		Time:           time.Now().UTC().Format(time.RFC3339),
+		Host:           w.host,
+		Digest:         o.Digest,
+		Schema:         o.Schema,
+		Sample:         o.Text,
+		Count:          o.Count,
+		RowsExamined:   o.RowsExamined,
+		RowsSent:       o.RowsSent,
+		BytesRead:      bytesToHuman(o.BytesRead),
+		BytesWrite:     bytesToHuman(o.BytesWrite),
+		ReadThreshold:  bytesToHuman(readThreshold),
+		WriteThreshold: bytesToHuman(writeThreshold),
+	})
+	if err != nil {
+		return fmt.Errorf("render payload: %w", err)
+	}
+	return w.deliver(ctx, buf.Bytes())
+}
+
+func (w *webhookReporter) Summary(r *summaryReport) {
+	if err := w.SendSummary(context.Background(), r); err != nil {
+		w.log.Warn("webhook summary delivery failed", "err", err)
+	}
+}
+
+// SendSummary posts a finished summary report rendered with the summary
+// template matching MON_WEBHOOK_TEMPLATE. With a custom template file there is
+// none, and the report is not sent: the endpoint expects that file's format.
+func (w *webhookReporter) SendSummary(ctx context.Context, r *summaryReport) error {
+	if w.summaryTmpl == nil {
+		w.log.Debug("webhook summary skipped, custom template", "period", r.Period)
+		return nil
+	}
+	var top strings.Builder
+	for _, d := range r.Top {
+		fmt.Fprintf(&top, "%s %s: read %s / write %s, %d executions\n", d.Digest, d.Schema, bytesToHuman(d.BytesRead), bytesToHuman(d.BytesWrite), d.Count)
+	}
+	if top.Len() == 0 {
+		top.WriteString("no traffic\n")
+	}
+	var buf bytes.Buffer
+	err := w.summaryTmpl.Execute(&buf, webhookSummary{
+		Host:       w.host,
+		Target:     r.Target,
+		Period:     r.Period,
+		Start:      r.Start.UTC().Format(time.RFC3339),
+		End:        r.End.UTC().Format(time.RFC3339),
+		Intervals:  r.Intervals,
+		Total:      r.Total,
+		Schemas:    r.Schemas,
+		Statements: r.Statements,
+		Top:        r.Top,
+		Files:      r.Files,
+		BytesRead:  bytesToHuman(r.Total.BytesRead),
+		BytesWrite: bytesToHuman(r.Total.BytesWrite),
+		TopText:    strings.TrimSuffix(top.String(), "\n"),
+	})
+	if err != nil {
+		return fmt.Errorf("render summary: %w", err)
+	}
+	return w.deliver(ctx, buf.Bytes())
+}
+
+// webhookStatusError is a non-2xx answer from the endpoint.
+type webhookStatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *webhookStatusError) Error() string { return "unexpected status " + e.Status }
+
+// webhookRetryable reports whether sending the same request again may succeed:
+// network errors, 5xx, 408 and 429. Other 4xx answers reject the payload itself
+// (bad template, wrong URL or credentials) and are neither retried nor spooled.
+func webhookRetryable(err error) bool {
+	var se *webhookStatusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	return se.Code >= 500 || se.Code == http.StatusRequestTimeout || se.Code == http.StatusTooManyRequests
+}
+
+// deliver posts body to every URL, spooling the ones that still fail after
+// retries or could not be sent before ctx was done.
+func (w *webhookReporter) deliver(ctx context.Context, body []byte) error {
+	var errs []error
+	for _, u := range w.urls {
+		if err := w.postWithRetry(ctx, u, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u, err))
+			if w.spoolDir != "" && webhookRetryable(err) {
+				if serr := w.spool(spooledRequest{URL: u, Body: string(body)}); serr != nil {
+					errs = append(errs, fmt.Errorf("spool: %w", serr))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *webhookReporter) postWithRetry(ctx context.Context, url string, body []byte) error {
+	delay := w.backoff
+	var err error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return err
+			case <-w.stop:
+				return err
+			}
+			delay = min(delay*2, webhookMaxBackoff)
+		}
+		if err = w.post(ctx, url, body); err == nil || !webhookRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// worstCase is how long one delivery to one URL can take when every attempt times out.
+func (w *webhookReporter) worstCase() time.Duration {
+	d, delay := time.Duration(w.retries+1)*w.client.Timeout, w.backoff
+	for i := 0; i < w.retries; i++ {
+		d += delay
+		delay = min(delay*2, webhookMaxBackoff)
+	}
+	return d
+}
+
+func (w *webhookReporter) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.contentType)
+	req.Header.Set("User-Agent", "mysql-top-throughput-analyzer")
+	if len(w.secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHdr, ts)
+		req.Header.Set(webhookSignatureHdr, webhookSignaturePref+signWebhook(w.secret, ts, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// signWebhook returns hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Receivers recompute it and reject stale timestamps to prevent replays.
+func signWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// spool writes req to the spool directory, evicting the oldest files beyond spoolMax.
+func (w *webhookReporter) spool(req spooledRequest) error {
+	w.spoolMu.Lock()
+	defer w.spoolMu.Unlock()
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(w.spoolDir, fmt.Sprintf("%020d.json", time.Now().UnixNano()))
+	if err := writeFileAtomic(name, data); err != nil {
+		return err
+	}
+	files := w.spoolFiles()
+	for len(files) > w.spoolMax && w.spoolMax > 0 {
+		w.log.Warn("webhook spool full, dropping oldest", "file", files[0])
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// spoolFiles lists spooled requests oldest first.
+func (w *webhookReporter) spoolFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(w.spoolDir, "*.json"))
+	sort.Strings(files)
+	return files
+}
+
+func (w *webhookReporter) replayLoop() {
+	defer w.stopped.Done()
+	t := time.NewTicker(webhookReplayEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.replaySpool()
+		}
+	}
+}
+
+// replaySpool re-sends spooled requests oldest first and stops at the first
+// failure that may go away; requests the endpoint rejects are dropped. The
+// spool is read and cleaned up under spoolMu but posted without it, so alerts
+// failing meanwhile can still be spooled.
+func (w *webhookReporter) replaySpool() {
+	type spooled struct {
+		file string
+		req  spooledRequest
+	}
+	var pending []spooled
+	w.spoolMu.Lock()
+	for _, f := range w.spoolFiles() {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var req spooledRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			w.log.Warn("webhook spool: dropping unreadable file", "file", f, "err", err)
+			_ = os.Remove(f)
+			continue
+		}
+		pending = append(pending, spooled{f, req})
+	}
+	w.spoolMu.Unlock()
+
+	var done []string
+	for _, s := range pending {
+		if err := w.post(context.Background(), s.req.URL, []byte(s.req.Body)); err != nil {
+			if webhookRetryable(err) {
+				break
+			}
+			w.log.Warn("webhook spool: dropping rejected request", "url", s.req.URL, "file", filepath.Base(s.file), "err", err)
+		} else {
+			w.log.Info("webhook spool: delivered", "url", s.req.URL, "file", filepath.Base(s.file))
+		}
+		done = append(done, s.file)
+	}
+
+	w.spoolMu.Lock()
+	defer w.spoolMu.Unlock()
+	for _, f := range done {
+		_ = os.Remove(f)
+	}
+}
+
+func (w *webhookReporter) Shutdown() {
+	close(w.stop)
+	w.stopped.Wait()
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestWebhook(t *testing.T, url string, edit func(c *config)) *webhookReporter {
+	t.Helper()
+	c := testConfig(func(c *config) {
+		c.webhookURLs = []string{url}
+		if edit != nil {
+			edit(c)
+		}
+	})
+	r, err := NewWebhookReporter(c, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := r.(*webhookReporter)
+	w.backoff = 10 * time.Millisecond
+	t.Cleanup(w.Shutdown)
+	return w
+}
+
+var testOffender = offender{Schema: "shop", Digest: "abc123", Text: "SELECT * FROM orders WHERE note = \"<x>\"", BytesRead: 2 << 20, BytesWrite: 512, RowsExamined: 4096, RowsSent: 8, Count: 3}
+
+func TestWebhookTemplates(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		check    func(t *testing.T, payload map[string]any)
+	}{
+		{"json", func(t *testing.T, p map[string]any) {
+			if p["event"] != "alert" || p["digest"] != "abc123" || p["schema"] != "shop" || p["count"] != float64(3) {
+				t.Errorf("unexpected json payload %v", p)
+			}
+			if p["sample"] != testOffender.Text || p["actualRead"] != bytesToHuman(testOffender.BytesRead) {
+				t.Errorf("sample/actualRead = %v / %v", p["sample"], p["actualRead"])
+			}
+		}},
+		{"slack", func(t *testing.T, p map[string]any) {
+			text, _ := p["text"].(string)
+			blocks, _ := p["blocks"].([]any)
+			if !strings.Contains(text, "MySQL throughput alert") || len(blocks) != 3 {
+				t.Errorf("unexpected slack payload %v", p)
+			}
+		}},
+		{"teams", func(t *testing.T, p map[string]any) {
+			sections, _ := p["sections"].([]any)
+			if p["@type"] != "MessageCard" || len(sections) != 1 {
+				t.Fatalf("unexpected teams payload %v", p)
+			}
+			text, _ := sections[0].(map[string]any)["text"].(string)
+			if !strings.Contains(text, "&lt;x&gt;") {
+				t.Errorf("sample not HTML-escaped: %q", text)
+			}
+		}},
+	} {
+		t.Run(tc.template, func(t *testing.T) {
+			sink := newHTTPRecorder(t)
+			w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookTemplate = tc.template })
+			if err := w.SendAlert(context.Background(), testOffender, 1<<20, 1<<20); err != nil {
+				t.Fatal(err)
+			}
+			if len(sink.requests()) != 1 {
+				t.Fatalf("got %d requests, want 1", len(sink.requests()))
+			}
+			var payload map[string]any
+			if err := json.Unmarshal([]byte(string(sink.requests()[0].Body)), &payload); err != nil {
+				t.Fatalf("payload is not valid JSON: %v\n%s", err, string(sink.requests()[0].Body))
+			}
+			tc.check(t, payload)
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	sink := newHTTPRecorder(t)
+	w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookSecret = "s3cret" })
+	if err := w.SendAlert(context.Background(), testOffender, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	ts := sink.requests()[0].Header.Get(webhookTimestampHdr)
+	sig := sink.requests()[0].Header.Get(webhookSignatureHdr)
+	if ts == "" || sig == "" {
+		t.Fatalf("missing signature headers: %v", sink.requests()[0].Header)
+	}
+	body := []byte(string(sink.requests()[0].Body))
+	if want := webhookSignaturePref + signWebhook([]byte("s3cret"), ts, body); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(ts + "." + string(body)))
+	if want := webhookSignaturePref + hex.EncodeToString(mac.Sum(nil)); sig != want {
+		t.Errorf("signature does not verify as HMAC-SHA256(secret, ts.body): %q", sig)
+	}
+}
+
+func TestWebhookUnsignedWithoutSecret(t *testing.T) {
+	sink := newHTTPRecorder(t)
+	w := newTestWebhook(t, sink.URL(), nil)
+	if err := w.SendAlert(context.Background(), testOffender, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if sig := sink.requests()[0].Header.Get(webhookSignatureHdr); sig != "" {
+		t.Errorf("unexpected signature %q without a secret", sig)
+	}
+}
+
+func TestWebhookRetriesServerErrorsWithBackoff(t *testing.T) {
+	sink := newHTTPRecorder(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable)
+	w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookRetries = 3 })
+	if err := w.SendAlert(context.Background(), testOffender, 1, 1); err != nil {
+		t.Fatalf("delivery failed after retries: %v", err)
+	}
+	reqs := sink.requests()
+	if len(reqs) != 4 {
+		t.Fatalf("got %d attempts, want 4", len(reqs))
+	}
+	// Delays double from the base backoff: 10ms, 20ms, 40ms
+	for i, want := range []time.Duration{10, 20, 40} {
+		if gap := reqs[i+1].At.Sub(reqs[i].At); gap < want*time.Millisecond {
+			t.Errorf("gap before attempt %d = %s, want at least %dms", i+2, gap, want)
+		}
+	}
+}
+
+func TestWebhookGivesUpAfterRetries(t *testing.T) {
+	sink := newHTTPRecorder(t, 500, 500, 500, 500)
+	w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookRetries = 2 })
+	if err := w.SendAlert(context.Background(), testOffender, 1, 1); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := len(sink.requests()); n != 3 {
+		t.Errorf("got %d attempts, want 3", n)
+	}
+}
+
+func TestWebhookClientErrors(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		attempts int
+		spooled  int
+	}{
+		{http.StatusBadRequest, 1, 0},
+		{http.StatusUnauthorized, 1, 0},
+		{http.StatusNotFound, 1, 0},
+		{http.StatusRequestTimeout, 3, 1},
+		{http.StatusTooManyRequests, 3, 1},
+	} {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			sink := newHTTPRecorder(t, tc.status, tc.status, tc.status)
+			dir := t.TempDir()
+			w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookRetries = 2; c.webhookSpoolDir = dir })
+			if err := w.SendAlert(context.Background(), testOffender, 1, 1); err == nil {
+				t.Fatal("expected an error")
+			}
+			if n := len(sink.requests()); n != tc.attempts {
+				t.Errorf("got %d attempts, want %d", n, tc.attempts)
+			}
+			if n := len(w.spoolFiles()); n != tc.spooled {
+				t.Errorf("got %d spooled requests, want %d", n, tc.spooled)
+			}
+		})
+	}
+}
+
+func TestWebhookSpoolReplay(t *testing.T) {
+	sink := newHTTPRecorder(t)
+	sink.setStatuses(503, 503)
+	dir := t.TempDir()
+	w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookSpoolDir = dir })
+
+	first, second := testOffender, testOffender
+	second.Digest = "def456"
+	for _, o := range []offender{first, second} {
+		if err := w.SendAlert(context.Background(), o, 1, 1); err == nil {
+			t.Fatal("expected delivery to fail while the endpoint is down")
+		}
+	}
+	files := w.spoolFiles()
+	if len(files) != 2 {
+		t.Fatalf("got %d spool files, want 2", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	var req spooledRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.URL != sink.URL() || !strings.Contains(req.Body, `"abc123"`) {
+		t.Fatalf("unexpected spool file %s: %s", filepath.Base(files[0]), data)
+	}
+
+	// Still down: replay stops at the first failure and keeps everything
+	sink.setStatuses(503)
+	w.replaySpool()
+	if n := len(w.spoolFiles()); n != 2 {
+		t.Fatalf("got %d spool files after failed replay, want 2", n)
+	}
+
+	// Recovered: both are delivered oldest first and removed
+	before := len(sink.requests())
+	w.replaySpool()
+	if n := len(w.spoolFiles()); n != 0 {
+		t.Fatalf("got %d spool files after replay, want 0", n)
+	}
+	got := sink.requests()[before:]
+	if len(got) != 2 || !strings.Contains(string(got[0].Body), `"abc123"`) || !strings.Contains(string(got[1].Body), `"def456"`) {
+		t.Errorf("replayed bodies out of order or missing: %+v", got)
+	}
+}
+
+func TestWebhookSpoolReplayDropsRejected(t *testing.T) {
+	sink := newHTTPRecorder(t, 503, 503)
+	dir := t.TempDir()
+	w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookSpoolDir = dir })
+	for i := 0; i < 2; i++ {
+		_ = w.SendAlert(context.Background(), testOffender, 1, 1)
+	}
+	// The endpoint now rejects the first request; it must not block the second
+	sink.setStatuses(http.StatusBadRequest)
+	w.replaySpool()
+	if n := len(w.spoolFiles()); n != 0 {
+		t.Errorf("got %d spool files, want 0", n)
+	}
+	if n := len(sink.requests()); n != 4 {
+		t.Errorf("got %d requests, want 4", n)
+	}
+}
+
+func TestWebhookSpoolReplayDoesNotBlockSpooling(t *testing.T) {
+	release := make(chan struct{})
+	replaying := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case replaying <- struct{}{}:
+			<-release
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	dir := t.TempDir()
+	w := newTestWebhook(t, srv.URL, func(c *config) { c.webhookSpoolDir = dir; c.webhookRetries = 0 })
+	old := filepath.Join(dir, fmt.Sprintf("%020d.json", 1))
+	if err := writeFileAtomic(old, []byte(`{"url":"`+srv.URL+`","body":"{}"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := make(chan struct{})
+	go func() { w.replaySpool(); close(replayed) }()
+	<-replaying
+	// The replay is stuck in its POST; a failing alert must still reach the spool
+	spooled := make(chan error, 1)
+	go func() { spooled <- w.SendAlert(context.Background(), testOffender, 1, 1) }()
+	select {
+	case <-spooled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("spooling blocked behind the replay's POST")
+	}
+	if n := len(w.spoolFiles()); n != 2 {
+		t.Errorf("got %d spool files during replay, want 2", n)
+	}
+	release <- struct{}{}
+	<-replayed
+	if files := w.spoolFiles(); len(files) != 1 || files[0] == old {
+		t.Errorf("spool after replay = %v, want only the new alert", files)
+	}
+}
+
+func TestWebhookSpoolBounded(t *testing.T) {
+	sink := newHTTPRecorder(t, 503, 503, 503, 503)
+	dir := t.TempDir()
+	w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookSpoolDir = dir; c.webhookSpoolMax = 2 })
+	for i := 0; i < 4; i++ {
+		_ = w.SendAlert(context.Background(), testOffender, 1, 1)
+	}
+	if n := len(w.spoolFiles()); n != 2 {
+		t.Errorf("got %d spool files, want 2", n)
+	}
+}
+
+func TestWebhookSummaryTemplates(t *testing.T) {
+	report := &summaryReport{
+		Period: "day", Target: "db:3306", Intervals: 8640,
+		Start: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		Total: summaryTotals{Digests: 2, Count: 10, BytesRead: 3 << 20, BytesWrite: 1 << 10},
+		Top:   []summaryDigest{{Schema: "shop", Digest: "abc123", Sample: "SELECT <1>", summaryTotals: summaryTotals{Count: 7, BytesRead: 3 << 20}}},
+	}
+	for _, tc := range []struct {
+		template string
+		check    func(t *testing.T, payload map[string]any)
+	}{
+		{"json", func(t *testing.T, p map[string]any) {
+			total, _ := p["total"].(map[string]any)
+			if p["event"] != "summary" || p["period"] != "day" || p["start"] != "2026-10-17T00:00:00Z" || total["count"] != float64(10) {
+				t.Errorf("unexpected json summary %v", p)
+			}
+		}},
+		{"slack", func(t *testing.T, p map[string]any) {
+			blocks, _ := p["blocks"].([]any)
+			if text, _ := p["text"].(string); !strings.Contains(text, "day summary for db:3306") || len(blocks) != 3 {
+				t.Fatalf("unexpected slack summary %v", p)
+			}
+			top, _ := blocks[2].(map[string]any)["text"].(map[string]any)["text"].(string)
+			if !strings.Contains(top, "abc123 shop") {
+				t.Errorf("top digests missing: %q", top)
+			}
+		}},
+		{"teams", func(t *testing.T, p map[string]any) {
+			if p["@type"] != "MessageCard" || !strings.Contains(p["title"].(string), "day summary") {
+				t.Errorf("unexpected teams summary %v", p)
+			}
+		}},
+	} {
+		t.Run(tc.template, func(t *testing.T) {
+			sink := newHTTPRecorder(t)
+			w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookTemplate = tc.template })
+			if err := w.SendSummary(context.Background(), report); err != nil {
+				t.Fatal(err)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal([]byte(string(sink.requests()[0].Body)), &payload); err != nil {
+				t.Fatalf("summary is not valid JSON: %v\n%s", err, string(sink.requests()[0].Body))
+			}
+			tc.check(t, payload)
+		})
+	}
+
+	t.Run("custom", func(t *testing.T) {
+		sink := newHTTPRecorder(t)
+		path := filepath.Join(t.TempDir(), "alert.tmpl")
+		if err := os.WriteFile(path, []byte(`{"msg":{{json .Digest}}}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w := newTestWebhook(t, sink.URL(), func(c *config) { c.webhookTemplate = path })
+		if err := w.SendSummary(context.Background(), report); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(sink.requests()); n != 0 {
+			t.Errorf("summary sent with a custom alert template (%d requests)", n)
+		}
+	})
+}
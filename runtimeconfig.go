@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingKind decides how a runtime setting is parsed and rendered.
+type settingKind int
+
+const (
+	kindDuration settingKind = iota
+	kindBytes                // uint64; accepts 5MB-style strings
+	kindUint                 // uint64
+	kindInt
+	kindBool
+	kindList // []string; comma-separated in env
+	kindLines
+)
+
+// runtimeSetting is a Config value that may change while the monitor runs.
+type runtimeSetting struct {
+	name     string // JSON name in /api/v1/config
+	env      string // MON_* variable re-read on SIGHUP
+	flag     string // command-line flag that takes precedence over env, if any
+	legacy   string // older flag that also sets it, e.g. -threshold for both thresholds
+	kind     settingKind
+	get      func(Config) any
+	set      func(Config, any)
+	validate func(any) error
+}
+
+// runtimeSettings lists what /api/v1/config and SIGHUP may change: thresholds,
+// interval, top-N and ignore rules. Everything else needs a restart.
+var runtimeSettings = []runtimeSetting{
+	{name: "interval", env: "MON_INTERVAL", flag: "interval", kind: kindDuration,
+		get: func(c Config) any { return c.Interval() }, set: func(c Config, v any) { c.SetInterval(v.(time.Duration)) },
+		validate: func(v any) error {
+			if v.(time.Duration) <= 0 {
+				return errors.New("must be positive")
+			}
+			return nil
+		}},
+	{name: "readThreshold", env: "MON_READ_THRESHOLD", flag: "read-threshold", legacy: "threshold", kind: kindBytes,
+		get: func(c Config) any { return c.ReadThreshold() }, set: func(c Config, v any) { c.SetReadThreshold(v.(uint64)) }},
+	{name: "writeThreshold", env: "MON_WRITE_THRESHOLD", flag: "write-threshold", legacy: "threshold", kind: kindBytes,
+		get: func(c Config) any { return c.WriteThreshold() }, set: func(c Config, v any) { c.SetWriteThreshold(v.(uint64)) }},
+	{name: "minPrintBytes", env: "MON_MIN_PRINT_BYTES", flag: "min-print-bytes", kind: kindBytes,
+		get: func(c Config) any { return c.MinPrintBytes() }, set: func(c Config, v any) { c.SetMinPrintBytes(v.(uint64)) }},
+	{name: "readRowsThreshold", env: "MON_READ_ROWS_THRESHOLD", flag: "read-rows-threshold", kind: kindUint,
+		get: func(c Config) any { return c.ReadRowsThreshold() }, set: func(c Config, v any) { c.SetReadRowsThreshold(v.(uint64)) }},
+	{name: "writeRowsThreshold", env: "MON_WRITE_ROWS_THRESHOLD", flag: "write-rows-threshold", kind: kindUint,
+		get: func(c Config) any { return c.WriteRowsThreshold() }, set: func(c Config, v any) { c.SetWriteRowsThreshold(v.(uint64)) }},
+	{name: "minPrintRows", env: "MON_MIN_PRINT_ROWS", flag: "min-print-rows", kind: kindUint,
+		get: func(c Config) any { return c.MinPrintRows() }, set: func(c Config, v any) { c.SetMinPrintRows(v.(uint64)) }},
+	{name: "avgRowRead", env: "MON_AVG_READ_BYTES", flag: "avg-read-bytes", kind: kindUint,
+		get: func(c Config) any { return c.AvgRowRead() }, set: func(c Config, v any) { c.SetAvgRowRead(v.(uint64)) }, validate: positiveUint},
+	{name: "avgRowSent", env: "MON_AVG_SENT_BYTES", flag: "avg-sent-bytes", kind: kindUint,
+		get: func(c Config) any { return c.AvgRowSent() }, set: func(c Config, v any) { c.SetAvgRowSent(v.(uint64)) }, validate: positiveUint},
+	{name: "top", env: "MON_TOP", flag: "top", kind: kindInt,
+		get: func(c Config) any { return c.TopN() }, set: func(c Config, v any) { c.SetTopN(v.(int)) },
+		validate: func(v any) error { return intAtLeast(v, 0) }},
+	{name: "alertResolveAfter", env: "MON_ALERT_RESOLVE_AFTER", kind: kindInt,
+		get: func(c Config) any { return c.AlertResolveAfter() }, set: func(c Config, v any) { c.SetAlertResolveAfter(v.(int)) },
+		validate: func(v any) error { return intAtLeast(v, 1) }},
+	{name: "ignoreDigests", env: "MON_IGNORE_DIGESTS", kind: kindList,
+		get: func(c Config) any { return c.IgnoreDigests() }, set: func(c Config, v any) { c.SetIgnoreDigests(v.([]string)) }},
+	{name: "ignoreSchemas", env: "MON_IGNORE_SCHEMAS", kind: kindList,
+		get: func(c Config) any { return c.IgnoreSchemas() }, set: func(c Config, v any) { c.SetIgnoreSchemas(v.([]string)) }},
+	{name: "ignoreUsers", env: "MON_IGNORE_USERS", kind: kindList,
+		get: func(c Config) any { return c.IgnoreUsers() }, set: func(c Config, v any) { c.SetIgnoreUsers(v.([]string)) }},
+	{name: "ignoreText", env: "MON_IGNORE_TEXT", kind: kindLines,
+		get: func(c Config) any { return c.IgnoreText() }, set: func(c Config, v any) { c.SetIgnoreText(v.([]string)) },
+		validate: func(v any) error {
+			for _, p := range v.([]string) {
+				if _, err := regexp.Compile(p); err != nil {
+					return fmt.Errorf("pattern %q: %w", p, err)
+				}
+			}
+			return nil
+		}},
+	{name: "ignoreSelf", env: "MON_IGNORE_SELF", kind: kindBool,
+		get: func(c Config) any { return c.IgnoreSelf() }, set: func(c Config, v any) { c.SetIgnoreSelf(v.(bool)) }},
+}
+
+func positiveUint(v any) error {
+	if v.(uint64) == 0 {
+		return errors.New("must be greater than 0")
+	}
+	return nil
+}
+
+func intAtLeast(v any, least int) error {
+	if v.(int) < least {
+		return fmt.Errorf("must be at least %d", least)
+	}
+	return nil
+}
+
+// parseString parses a setting from its env/flag spelling.
+func (s runtimeSetting) parseString(v string) (any, error) {
+	v = strings.TrimSpace(v)
+	switch s.kind {
+	case kindDuration:
+		return time.ParseDuration(v)
+	case kindBytes:
+		return parseBytesFlag(v)
+	case kindUint:
+		return strconv.ParseUint(v, 10, 64)
+	case kindInt:
+		return strconv.Atoi(v)
+	case kindBool:
+		switch strings.ToLower(v) {
+		case "1", "true", "yes", "on":
+			return true, nil
+		case "0", "false", "no", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean %q", v)
+	case kindList:
+		return splitList(v), nil
+	case kindLines:
+		return splitLines(v), nil
+	}
+	return nil, errors.New("unsupported setting")
+}
+
+// parseJSON accepts the JSON form (numbers, booleans, string arrays) or the env spelling as a string.
+func (s runtimeSetting) parseJSON(raw json.RawMessage) (any, error) {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return s.parseString(str)
+	}
+	switch s.kind {
+	case kindUint, kindBytes:
+		var n uint64
+		err := json.Unmarshal(raw, &n)
+		return n, err
+	case kindInt:
+		var n int
+		err := json.Unmarshal(raw, &n)
+		return n, err
+	case kindBool:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return b, err
+	case kindList, kindLines:
+		var l []string
+		err := json.Unmarshal(raw, &l)
+		return l, err
+	}
+	return nil, errors.New("expected a string")
+}
+
+// render formats a value for JSON output and audit logs.
+func (s runtimeSetting) render(v any) any {
+	switch x := v.(type) {
+	case time.Duration:
+		return x.String()
+	case []string:
+		if x == nil {
+			return []string{}
+		}
+	}
+	return v
+}
+
+// configChange is one applied setting change.
+type configChange struct {
+	Setting string `json:"setting"`
+	Old     any    `json:"old"`
+	New     any    `json:"new"`
+}
+
+// configManager applies runtime configuration changes from the API and from
+// SIGHUP reloads, validating them as a whole, logging an audit record per
+// change and rebuilding the ignore rules when they change.
+type configManager struct {
+	cfg    Config
+	filter DigestFilter
+	log    *slog.Logger
+	mu     sync.Mutex        // serializes updates
+	file   map[string]string // MON_CONFIG_FILE as last applied
+}
+
+func newConfigManager(cfg Config, filter DigestFilter, log *slog.Logger) *configManager {
+	return &configManager{cfg: cfg, filter: filter, log: log}
+}
+
+func lookupSetting(name string) (runtimeSetting, bool) {
+	for _, s := range runtimeSettings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return runtimeSetting{}, false
+}
+
+// settings returns the current runtime settings by name.
+func (m *configManager) settings() map[string]any {
+	out := make(map[string]any, len(runtimeSettings))
+	for _, s := range runtimeSettings {
+		out[s.name] = s.render(s.get(m.cfg))
+	}
+	return out
+}
+
+// apply validates every value first and only then changes anything, so a bad
+// request leaves the configuration untouched.
+func (m *configManager) apply(values map[string]any, source, caller string) ([]configChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, _ := lookupSetting(name)
+		if s.validate != nil {
+			if err := s.validate(values[name]); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	var changes []configChange
+	rules := false
+	for _, name := range names {
+		s, _ := lookupSetting(name)
+		old := s.get(m.cfg)
+		if reflect.DeepEqual(s.render(old), s.render(values[name])) {
+			continue
+		}
+		s.set(m.cfg, values[name])
+		c := configChange{Setting: name, Old: s.render(old), New: s.render(values[name])}
+		changes = append(changes, c)
+		rules = rules || strings.HasPrefix(name, "ignore")
+		m.log.Info("config changed", "setting", c.Setting, "old", c.Old, "new", c.New, "source", source, "caller", caller)
+	}
+	if rules && m.filter != nil {
+		if err := m.filter.Reload(m.cfg); err != nil {
+			m.log.Error("ignore rules reload", "err", err)
+		}
+	}
+	return changes, nil
+}
+
+// Reload applies the runtime settings in MON_CONFIG_FILE. After the first
+// load only keys whose value changed in the file are applied, so a SIGHUP
+// (also sent by logrotate to reopen the log file) keeps changes made through
+// the API since. The environment cannot change while the process runs and was
+// applied by LoadConfig, so it is not re-read. Settings given as flags set on
+// flags (flag.CommandLine outside tests) keep their flag value.
+func (m *configManager) Reload(flags *flag.FlagSet, source string) ([]configChange, error) {
+	path := m.cfg.ConfigFile()
+	if path == "" {
+		return nil, nil
+	}
+	file, err := readEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	setFlags := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	m.mu.Lock()
+	last := m.file
+	m.mu.Unlock()
+	values := make(map[string]any)
+	for _, s := range runtimeSettings {
+		raw, ok := file[s.env]
+		if !ok || strings.TrimSpace(raw) == "" || setFlags[s.flag] || setFlags[s.legacy] {
+			continue
+		}
+		if prev, seen := last[s.env]; seen && prev == raw {
+			continue
+		}
+		v, err := s.parseString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.env, err)
+		}
+		values[s.name] = v
+	}
+	changes, err := m.apply(values, source, "local")
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.file = file
+	m.mu.Unlock()
+	return changes, nil
+}
+
+// readEnvFile parses KEY=VALUE lines; blank lines and # comments are skipped
+// and values may be single- or double-quoted.
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		v = strings.TrimSpace(v)
+		if uq, err := strconv.Unquote(v); err == nil && strings.HasPrefix(v, `"`) {
+			v = uq
+		} else if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+			v = v[1 : len(v)-1]
+		}
+		out[strings.TrimSpace(k)] = v
+	}
+	return out, sc.Err()
+}
+
+// register mounts GET and PATCH /api/v1/config; PATCH needs an authenticated caller.
+func (m *configManager) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/config", m.handleGet)
+	mux.HandleFunc("PATCH /api/v1/config", requireWriteAuth(m.cfg, m.handlePatch))
+}
+
+func (m *configManager) handleGet(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"settings": m.settings()})
+}
+
+// PATCH /api/v1/config with a JSON object of settings to change, e.g.
+// {"readThreshold":"10MB","top":10}. Unknown or invalid settings reject the
+// whole request.
+func (m *configManager) handlePatch(w http.ResponseWriter, r *http.Request) {
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON object: "+err.Error())
+		return
+	}
+	values := make(map[string]any, len(body))
+	for name, raw := range body {
+		s, ok := lookupSetting(name)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("%s: not a runtime setting", name))
+			return
+		}
+		v, err := s.parseJSON(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		values[name] = v
+	}
+	changes, err := m.apply(values, "api", requestCaller(r))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if changes == nil {
+		changes = []configChange{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"changed": changes, "settings": m.settings()})
+}
+
+// requestCaller identifies who made a request for audit logs: the basic auth
+// user, or "bearer" for token auth, followed by the remote address. Only
+// credentials httpAuth verified count; without auth every caller is anonymous.
+func requestCaller(r *http.Request) string {
+	who := authenticatedCaller(r)
+	if who == "" {
+		who = "anonymous"
+	}
+	return who + "@" + r.RemoteAddr
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// noFlags is a flag set with nothing given on the command line.
+func noFlags() *flag.FlagSet { return flag.NewFlagSet("test", flag.ContinueOnError) }
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "monitor.env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReloadKeepsLegacyThresholdFlag(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("threshold", "", "")
+	if err := flags.Parse([]string{"-threshold", "1GB"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{readThreshold: 1 << 30, writeThreshold: 1 << 30, topN: 10,
+		configFile: writeConfigFile(t, "MON_READ_THRESHOLD=5MB\nMON_WRITE_THRESHOLD=6MB\nMON_TOP=7\n")}
+	m := newConfigManager(cfg, nil, testLogger())
+	changes, err := m.Reload(flags, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadThreshold() != 1<<30 || cfg.WriteThreshold() != 1<<30 {
+		t.Errorf("thresholds = %d/%d, -threshold must win over the environment", cfg.ReadThreshold(), cfg.WriteThreshold())
+	}
+	if cfg.TopN() != 7 || len(changes) != 1 || changes[0].Setting != "top" {
+		t.Errorf("changes = %+v, want only top reloaded", changes)
+	}
+}
+
+func TestRequestCallerOnlyTrustsVerifiedCredentials(t *testing.T) {
+	caller := func(auth *httpAuth, set func(r *http.Request)) string {
+		var got string
+		h := auth.wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = requestCaller(r) }))
+		r := httptest.NewRequest(http.MethodPatch, "/api/v1/config", nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		set(r)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		return got
+	}
+	basic := func(user, pass string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, pass) }
+	}
+
+	// Without auth, a claimed user name is not evidence of anything
+	if got := caller(&httpAuth{}, basic("admin", "x")); got != "anonymous@10.0.0.1:5000" {
+		t.Errorf("auth disabled: caller = %q", got)
+	}
+	auth := &httpAuth{basic: map[string]string{"ana": "pw"}, tokens: []string{"tok"}}
+	if got := caller(auth, basic("ana", "pw")); got != "ana@10.0.0.1:5000" {
+		t.Errorf("basic auth: caller = %q", got)
+	}
+	if got := caller(auth, func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok") }); got != "bearer@10.0.0.1:5000" {
+		t.Errorf("bearer token: caller = %q", got)
+	}
+	if got := caller(auth, basic("ana", "wrong")); got != "" {
+		t.Errorf("rejected request reached the handler as %q", got)
+	}
+}
+
+func TestConfigPatchNeedsAuth(t *testing.T) {
+	patch := func(cfg *config, auth *httpAuth, set func(*http.Request)) int {
+		m := newConfigManager(cfg, nil, testLogger())
+		mux := http.NewServeMux()
+		m.register(mux)
+		r := httptest.NewRequest(http.MethodPatch, "/api/v1/config", strings.NewReader(`{"top":5}`))
+		if set != nil {
+			set(r)
+		}
+		rec := httptest.NewRecorder()
+		auth.wrap(mux).ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	cfg := &config{topN: 10}
+	if code := patch(cfg, &httpAuth{}, nil); code != http.StatusForbidden || cfg.TopN() != 10 {
+		t.Errorf("auth off: status %d, top %d; want 403 and no change", code, cfg.TopN())
+	}
+	cfg.httpAnonymousWrites = true
+	if code := patch(cfg, &httpAuth{}, nil); code != http.StatusOK || cfg.TopN() != 5 {
+		t.Errorf("anonymous writes allowed: status %d, top %d; want 200 and top 5", code, cfg.TopN())
+	}
+	cfg = &config{topN: 10}
+	auth := &httpAuth{basic: map[string]string{"ana": "pw"}}
+	if code := patch(cfg, auth, func(r *http.Request) { r.SetBasicAuth("ana", "pw") }); code != http.StatusOK || cfg.TopN() != 5 {
+		t.Errorf("authenticated: status %d, top %d; want 200 and top 5", code, cfg.TopN())
+	}
+}
+
+func TestReloadKeepsAPIChangesUnlessTheFileChanges(t *testing.T) {
+	cfg := &config{topN: 10, minPrintRows: 1}
+	cfg.configFile = writeConfigFile(t, "MON_TOP=7\nMON_MIN_PRINT_ROWS=5\n")
+	m := newConfigManager(cfg, nil, testLogger())
+	if _, err := m.Reload(noFlags(), "file"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TopN() != 7 || cfg.MinPrintRows() != 5 {
+		t.Fatalf("startup load: top %d, minPrintRows %d", cfg.TopN(), cfg.MinPrintRows())
+	}
+
+	// Changed through the API, then SIGHUP (logrotate) with the file untouched
+	if _, err := m.apply(map[string]any{"top": 3, "minPrintRows": uint64(9)}, "api", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := m.Reload(noFlags(), "sighup"); err != nil || len(changes) != 0 {
+		t.Fatalf("unchanged file: changes %+v, err %v; want none", changes, err)
+	}
+	if cfg.TopN() != 3 || cfg.MinPrintRows() != 9 {
+		t.Errorf("SIGHUP reverted API changes: top %d, minPrintRows %d", cfg.TopN(), cfg.MinPrintRows())
+	}
+
+	// Editing a key in the file applies that key only
+	if err := os.WriteFile(cfg.configFile, []byte("MON_TOP=12\nMON_MIN_PRINT_ROWS=5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reload(noFlags(), "sighup"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TopN() != 12 || cfg.MinPrintRows() != 9 {
+		t.Errorf("after editing MON_TOP: top %d, minPrintRows %d; want 12 and 9", cfg.TopN(), cfg.MinPrintRows())
+	}
+
+	// A bad file changes nothing and is retried in full next time
+	if err := os.WriteFile(cfg.configFile, []byte("MON_TOP=-1\nMON_MIN_PRINT_ROWS=6\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reload(noFlags(), "sighup"); err == nil {
+		t.Error("invalid top accepted")
+	}
+	if cfg.TopN() != 12 || cfg.MinPrintRows() != 9 {
+		t.Errorf("invalid file applied: top %d, minPrintRows %d", cfg.TopN(), cfg.MinPrintRows())
+	}
+}
+
+func TestReloadWithoutConfigFile(t *testing.T) {
+	t.Setenv("MON_TOP", "7")
+	cfg := &config{topN: 3}
+	m := newConfigManager(cfg, nil, testLogger())
+	if changes, err := m.Reload(noFlags(), "sighup"); err != nil || changes != nil || cfg.TopN() != 3 {
+		t.Errorf("reload without MON_CONFIG_FILE: changes %+v, err %v, top %d; want nothing", changes, err, cfg.TopN())
+	}
+}
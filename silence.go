@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alert rules a silence can be limited to: which threshold was exceeded.
+const (
+	ruleRead  = "read"
+	ruleWrite = "write"
+)
+
+// silenceRetention is how long expired silences stay listed before they are dropped.
+const silenceRetention = 24 * time.Hour
+
+// alertSilencer is implemented by observers that can mute alerts. The monitor
+// asks it before handing an alert to the reporter; rules are the thresholds
+// the offender exceeded. It returns the id of the silence that applies, if any.
+type alertSilencer interface {
+	Silenced(o offender, rules []string, now time.Time) string
+}
+
+// silencedAlert is an alert that was muted instead of being reported.
+type silencedAlert struct {
+	offender
+	SilenceID string
+}
+
+// silence mutes matching alerts between StartsAt and EndsAt. Empty matchers
+// match everything, but at least one must be set.
+type silence struct {
+	ID         string    `json:"id"`
+	Digest     string    `json:"digest,omitempty"`
+	Schema     string    `json:"schema,omitempty"`
+	Rule       string    `json:"rule,omitempty"`    // read|write
+	Pattern    string    `json:"pattern,omitempty"` // regex on the (redacted) sample
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	Author     string    `json:"author"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Suppressed uint64    `json:"suppressed"` // alerts muted so far
+
+	re *regexp.Regexp
+}
+
+func (s *silence) state(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return "pending"
+	case now.Before(s.EndsAt):
+		return "active"
+	}
+	return "expired"
+}
+
+func (s *silence) matches(o offender, rule string) bool {
+	return (s.Digest == "" || s.Digest == o.Digest) &&
+		(s.Schema == "" || strings.EqualFold(s.Schema, o.Schema)) &&
+		(s.Rule == "" || s.Rule == rule) &&
+		(s.re == nil || s.re.MatchString(o.Text))
+}
+
+// silenceStore keeps silences, persists them to MON_SILENCE_FILE and serves
+// /api/v1/silences. API changes are saved right away; it is an
+// intervalObserver so suppression counters are saved at most every
+// MON_SILENCE_SAVE_INTERVAL and long-expired silences dropped as the monitor runs.
+type silenceStore struct {
+	cfg          Config
+	path         string
+	log          *slog.Logger
+	saveInterval time.Duration
+
+	mu       sync.Mutex
+	silences map[string]*silence
+	dirty    bool
+	savedAt  time.Time
+}
+
+func newSilenceStore(cfg Config, log *slog.Logger) (*silenceStore, error) {
+	s := &silenceStore{cfg: cfg, path: cfg.SilenceFile(), log: log, saveInterval: cfg.SilenceSaveInterval(), silences: make(map[string]*silence)}
+	if s.path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*silence
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	for _, sl := range list {
+		if sl.Pattern != "" {
+			if sl.re, err = regexp.Compile(sl.Pattern); err != nil {
+				return nil, fmt.Errorf("%s: silence %s: %w", s.path, sl.ID, err)
+			}
+		}
+		s.silences[sl.ID] = sl
+	}
+	log.Info("silences loaded", "path", s.path, "count", len(list))
+	return s, nil
+}
+
+func (s *silenceStore) Silenced(o offender, rules []string, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Every exceeded threshold must be covered, so muting reads keeps write alerts
+	var matched []*silence
+	for _, rule := range rules {
+		var hit *silence
+		for _, sl := range s.silences {
+			if sl.state(now) == "active" && sl.matches(o, rule) && (hit == nil || sl.ID < hit.ID) {
+				hit = sl
+			}
+		}
+		if hit == nil {
+			return ""
+		}
+		matched = append(matched, hit)
+	}
+	if len(matched) == 0 {
+		return ""
+	}
+	matched[0].Suppressed++
+	s.dirty = true
+	return matched[0].ID
+}
+
+func (s *silenceStore) ObserveInterval(t tick) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sl := range s.silences {
+		if t.At.Sub(sl.EndsAt) > silenceRetention {
+			delete(s.silences, id)
+			s.dirty = true
+		}
+	}
+	if s.dirty && t.At.Sub(s.savedAt) >= s.saveInterval {
+		s.saveLocked()
+		s.savedAt = t.At
+	}
+}
+
+// Close writes counters not saved yet.
+func (s *silenceStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirty {
+		s.saveLocked()
+	}
+	return nil
+}
+
+// saveLocked writes all silences to the state file. Caller holds mu.
+func (s *silenceStore) saveLocked() {
+	s.dirty = false
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+	if err != nil {
+		s.log.Warn("silences not saved", "path", s.path, "err", err)
+	}
+}
+
+func (s *silenceStore) sortedLocked() []*silence {
+	out := make([]*silence, 0, len(s.silences))
+	for _, sl := range s.silences {
+		out = append(out, sl)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// silenceRequest is the body of POST /api/v1/silences. Either endsAt or
+// duration sets the end; startsAt defaults to now.
+type silenceRequest struct {
+	Digest   string    `json:"digest"`
+	Schema   string    `json:"schema"`
+	Rule     string    `json:"rule"`
+	Pattern  string    `json:"pattern"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	Duration string    `json:"duration"`
+	Author   string    `json:"author"`
+	Comment  string    `json:"comment"`
+}
+
+func (req silenceRequest) build(now time.Time) (*silence, error) {
+	sl := &silence{
+		Digest:    strings.TrimSpace(req.Digest),
+		Schema:    strings.TrimSpace(req.Schema),
+		Rule:      strings.ToLower(strings.TrimSpace(req.Rule)),
+		Pattern:   req.Pattern,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Author:    strings.TrimSpace(req.Author),
+		Comment:   strings.TrimSpace(req.Comment),
+		CreatedAt: now,
+	}
+	if sl.Digest == "" && sl.Schema == "" && sl.Rule == "" && sl.Pattern == "" {
+		return nil, errors.New("at least one of digest, schema, rule or pattern is required")
+	}
+	if sl.Rule != "" && sl.Rule != ruleRead && sl.Rule != ruleWrite {
+		return nil, fmt.Errorf("rule must be %s or %s", ruleRead, ruleWrite)
+	}
+	if sl.Pattern != "" {
+		re, err := regexp.Compile(sl.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern: %w", err)
+		}
+		sl.re = re
+	}
+	if sl.Author == "" || sl.Comment == "" {
+		return nil, errors.New("author and comment are required")
+	}
+	if sl.StartsAt.IsZero() {
+		sl.StartsAt = now
+	}
+	if req.Duration != "" {
+		if !sl.EndsAt.IsZero() {
+			return nil, errors.New("set either endsAt or duration, not both")
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration %q", req.Duration)
+		}
+		sl.EndsAt = sl.StartsAt.Add(d)
+	}
+	if sl.EndsAt.IsZero() {
+		return nil, errors.New("endsAt or duration is required")
+	}
+	if !sl.EndsAt.After(sl.StartsAt) || !sl.EndsAt.After(now) {
+		return nil, errors.New("endsAt must be after startsAt and in the future")
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	sl.ID = hex.EncodeToString(id[:])
+	return sl, nil
+}
+
+// silenceView is a silence as returned by the API, with its current state.
+type silenceView struct {
+	*silence
+	State string `json:"state"`
+}
+
+// register mounts the silences API; creating and expiring need an authenticated caller.
+func (s *silenceStore) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/silences", s.handleList)
+	mux.HandleFunc("POST /api/v1/silences", requireWriteAuth(s.cfg, s.handleCreate))
+	mux.HandleFunc("GET /api/v1/silences/{id}", s.handleGet)
+	mux.HandleFunc("DELETE /api/v1/silences/{id}", requireWriteAuth(s.cfg, s.handleExpire))
+}
+
+// GET /api/v1/silences[?state=active|pending|expired]
+func (s *silenceStore) handleList(w http.ResponseWriter, r *http.Request) {
+	want := r.URL.Query().Get("state")
+	now := time.Now()
+	s.mu.Lock()
+	out := []silenceView{}
+	for _, sl := range s.sortedLocked() {
+		if st := sl.state(now); want == "" || want == st {
+			c := *sl
+			out = append(out, silenceView{&c, st})
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"silences": out})
+}
+
+func (s *silenceStore) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sl, ok := s.silences[r.PathValue("id")]
+	var v silenceView
+	if ok {
+		c := *sl
+		v = silenceView{&c, sl.state(time.Now())}
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "no such silence")
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// POST /api/v1/silences, e.g. {"digest":"…","duration":"2h","author":"ana","comment":"migration"}
+func (s *silenceStore) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req silenceRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid silence: "+err.Error())
+		return
+	}
+	if who := authenticatedCaller(r); who != "" && who != "bearer" && req.Author == "" {
+		req.Author = who
+	}
+	now := time.Now()
+	sl, err := req.build(now)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.silences[sl.ID] = sl
+	s.saveLocked()
+	v := silenceView{sl, sl.state(now)}
+	s.log.Info("silence created", "id", sl.ID, "digest", sl.Digest, "schema", sl.Schema, "rule", sl.Rule, "pattern", sl.Pattern,
+		"startsAt", sl.StartsAt, "endsAt", sl.EndsAt, "author", sl.Author, "comment", sl.Comment, "caller", requestCaller(r))
+	c := *sl
+	v.silence = &c
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// DELETE /api/v1/silences/{id} expires the silence now; it stays listed for a day.
+func (s *silenceStore) handleExpire(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	s.mu.Lock()
+	sl, ok := s.silences[r.PathValue("id")]
+	var v silenceView
+	if ok {
+		if sl.EndsAt.After(now) {
+			sl.EndsAt = now
+			if sl.StartsAt.After(now) {
+				sl.StartsAt = now
+			}
+			s.saveLocked()
+			s.log.Info("silence expired", "id", sl.ID, "author", sl.Author, "caller", requestCaller(r))
+		}
+		c := *sl
+		v = silenceView{&c, sl.state(now)}
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "no such silence")
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSilences(t *testing.T, cfg *config) (*silenceStore, http.Handler) {
+	t.Helper()
+	s, err := newSilenceStore(cfg, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.register(mux)
+	return s, mux
+}
+
+func TestSilenceWritesNeedAuth(t *testing.T) {
+	const body = `{"pattern":".*","duration":"1h","author":"x","comment":"mute all"}`
+	do := func(h http.Handler, method, path, body string, set func(*http.Request)) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if set != nil {
+			set(r)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	s, mux := newTestSilences(t, &config{})
+	open := (&httpAuth{}).wrap(mux)
+	if code := do(open, http.MethodPost, "/api/v1/silences", body, nil); code != http.StatusForbidden || len(s.silences) != 0 {
+		t.Errorf("auth off: POST status %d with %d silences, want 403 and none", code, len(s.silences))
+	}
+	s.silences["a1"] = &silence{ID: "a1", Pattern: ".*"}
+	if code := do(open, http.MethodDelete, "/api/v1/silences/a1", "", nil); code != http.StatusForbidden {
+		t.Errorf("auth off: DELETE status %d, want 403", code)
+	}
+	if code := do(open, http.MethodGet, "/api/v1/silences", "", nil); code != http.StatusOK {
+		t.Errorf("auth off: GET status %d, want 200", code)
+	}
+
+	s, mux = newTestSilences(t, &config{httpAnonymousWrites: true})
+	if code := do((&httpAuth{}).wrap(mux), http.MethodPost, "/api/v1/silences", body, nil); code != http.StatusCreated {
+		t.Errorf("anonymous writes allowed: POST status %d, want 201", code)
+	}
+
+	s, mux = newTestSilences(t, &config{})
+	auth := (&httpAuth{tokens: []string{"tok"}}).wrap(mux)
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok") }
+	if code := do(auth, http.MethodPost, "/api/v1/silences", body, bearer); code != http.StatusCreated || len(s.silences) != 1 {
+		t.Errorf("authenticated: POST status %d with %d silences, want 201 and one", code, len(s.silences))
+	}
+}
+
+func TestSilenceCountersSavedOnInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences.json")
+	s, mux := newTestSilences(t, &config{silenceFile: path, silenceSaveInterval: time.Minute, httpAnonymousWrites: true})
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/silences", strings.NewReader(`{"digest":"d1","duration":"1h","author":"x","comment":"y"}`))
+	rec := httptest.NewRecorder()
+	(&httpAuth{}).wrap(mux).ServeHTTP(rec, r)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	saved := func() uint64 {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var list []silence
+		if err := json.Unmarshal(data, &list); err != nil || len(list) != 1 {
+			t.Fatalf("silence file %s: %v", data, err)
+		}
+		return list[0].Suppressed
+	}
+	if saved() != 0 {
+		t.Fatal("created silence not saved right away")
+	}
+
+	// One suppressed alert per tick: written at most once per save interval
+	now := time.Now()
+	o := offender{Digest: "d1"}
+	for i := 0; i < 50; i++ {
+		if s.Silenced(o, []string{ruleRead}, now) == "" {
+			t.Fatal("alert not silenced")
+		}
+		s.ObserveInterval(tick{At: now})
+		now = now.Add(10 * time.Millisecond)
+	}
+	if got := saved(); got != 1 {
+		t.Errorf("counter on disk = %d after 50 ticks in 0.5s, want 1 (first tick only)", got)
+	}
+	s.ObserveInterval(tick{At: now.Add(time.Minute)})
+	if got := saved(); got != 50 {
+		t.Errorf("counter on disk = %d after the save interval, want 50", got)
+	}
+	s.Silenced(o, []string{ruleRead}, now)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := saved(); got != 51 {
+		t.Errorf("counter on disk = %d after Close, want 51", got)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// sseFilter selects which log lines an SSE client receives, from the query of
+// /logs: level (minimum, e.g. WARN), msg, digest, schema and target (exact,
+// repeat the parameter or separate with commas to accept several) and q (a
+// case-insensitive substring of the raw JSON line).
+type sseFilter struct {
+	minLevel *slog.Level
+	fields   map[string]map[string]bool // slog key -> accepted values
+	text     string                     // lower-cased
+}
+
+// sseFilterFields are the slog keys that may be filtered on by exact value.
+var sseFilterFields = []string{"msg", "digest", "schema", "target"}
+
+func parseSSEFilter(q url.Values) (*sseFilter, error) {
+	f := &sseFilter{fields: make(map[string]map[string]bool)}
+	if v := q.Get("level"); v != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid level %q", v)
+		}
+		f.minLevel = &l
+	}
+	for _, key := range sseFilterFields {
+		if !q.Has(key) {
+			continue
+		}
+		// An explicit empty value matches an empty field (e.g. schema=)
+		set := make(map[string]bool)
+		for _, v := range q[key] {
+			for _, p := range strings.Split(v, ",") {
+				set[strings.TrimSpace(p)] = true
+			}
+		}
+		f.fields[key] = set
+	}
+	f.text = strings.ToLower(q.Get("q"))
+	return f, nil
+}
+
+// active reports whether any filter is set.
+func (f *sseFilter) active() bool {
+	return f.minLevel != nil || len(f.fields) > 0 || f.text != ""
+}
+
+// match reports whether a JSON log line passes the filter. Lines that do not
+// parse only pass when no level or field filter is set.
+func (f *sseFilter) match(line string) bool {
+	if f.text != "" && !strings.Contains(strings.ToLower(line), f.text) {
+		return false
+	}
+	if f.minLevel == nil && len(f.fields) == 0 {
+		return true
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return false
+	}
+	if f.minLevel != nil {
+		s, _ := rec[slog.LevelKey].(string)
+		var l slog.Level
+		if l.UnmarshalText([]byte(s)) != nil || l < *f.minLevel {
+			return false
+		}
+	}
+	for key, set := range f.fields {
+		v, ok := rec[key]
+		if !ok {
+			return false
+		}
+		s, isString := v.(string)
+		if !isString {
+			s = fmt.Sprint(v)
+		}
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
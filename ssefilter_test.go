@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSSEFilterMatch(t *testing.T) {
+	const (
+		warn    = `{"level":"WARN","msg":"alert","schema":"billing","digest":"d1","bytes":42}`
+		info    = `{"level":"INFO","msg":"interval","schema":"","digest":"d2"}`
+		debug   = `{"level":"DEBUG","msg":"Tick","target":"db1"}`
+		garbage = `not json at all`
+	)
+	for _, tc := range []struct {
+		query string
+		line  string
+		want  bool
+	}{
+		{"", warn, true},
+		{"", garbage, true},
+		{"level=warn", warn, true},
+		{"level=WARN", info, false},
+		{"level=INFO", warn, true},
+		{"level=INFO", garbage, false},
+		{"schema=billing", warn, true},
+		{"schema=billing", info, false},
+		{"schema=shop,billing", warn, true},
+		{"schema=shop&schema=billing", warn, true},
+		{"schema=", info, true}, // explicit empty matches an empty field
+		{"schema=", debug, false},
+		{"target=db1", debug, true},
+		{"digest=d1&msg=alert", warn, true},
+		{"digest=d1&msg=interval", warn, false},
+		{"q=BILLING", warn, true},
+		{"q=billing", info, false},
+		{"q=json", garbage, true},
+		{"q=json&level=INFO", garbage, false},
+		{"level=WARN&schema=billing&q=alert", warn, true},
+	} {
+		q, _ := url.ParseQuery(tc.query)
+		f, err := parseSSEFilter(q)
+		if err != nil {
+			t.Fatalf("parseSSEFilter(%q): %v", tc.query, err)
+		}
+		if got := f.match(tc.line); got != tc.want {
+			t.Errorf("?%s match(%s) = %v, want %v", tc.query, tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestParseSSEFilter(t *testing.T) {
+	for _, tc := range []struct {
+		query  string
+		active bool
+		err    bool
+	}{
+		{"", false, false},
+		{"heartbeat=1s", false, false},
+		{"level=error", true, false},
+		{"schema=", true, false},
+		{"q=x", true, false},
+		{"level=loud", false, true},
+	} {
+		q, _ := url.ParseQuery(tc.query)
+		f, err := parseSSEFilter(q)
+		if (err != nil) != tc.err {
+			t.Errorf("parseSSEFilter(%q) err = %v, want err=%v", tc.query, err, tc.err)
+			continue
+		}
+		if err == nil && f.active() != tc.active {
+			t.Errorf("parseSSEFilter(%q).active() = %v, want %v", tc.query, f.active(), tc.active)
+		}
+	}
+}
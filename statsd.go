@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// statsdEmitter sends per-interval counters and gauges over UDP in plain
+// StatsD or DogStatsD (tagged) format: totals per schema, engine I/O, snapshot
+// timing and the top digests by estimated bytes. Digest metrics are the
+// high-cardinality part and can be sampled; sampled counters carry the @rate
+// suffix so the agent scales them back up. Lines are packed into packets of at
+// most MTU bytes.
+type statsdEmitter struct {
+	log        *slog.Logger
+	conn       net.Conn
+	prefix     string
+	tagged     bool
+	tags       string // ",k:v,..." static DogStatsD tags, pre-rendered
+	topN       int
+	sampleRate float64
+	mtu        int
+	avgRead    uint64
+	avgSent    uint64
+
+	packet strings.Builder
+}
+
+// newStatsdEmitter dials the agent; UDP dialing only resolves the address.
+func newStatsdEmitter(cfg Config, log *slog.Logger) (*statsdEmitter, error) {
+	var tagged bool
+	switch strings.ToLower(cfg.StatsdFormat()) {
+	case "statsd":
+	case "dogstatsd":
+		tagged = true
+	default:
+		return nil, fmt.Errorf("invalid statsd format %q (want statsd or dogstatsd)", cfg.StatsdFormat())
+	}
+	conn, err := net.Dial("udp", cfg.StatsdAddr())
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	s := &statsdEmitter{
+		log:        log,
+		conn:       conn,
+		prefix:     cfg.StatsdPrefix(),
+		tagged:     tagged,
+		topN:       cfg.StatsdTopDigests(),
+		sampleRate: cfg.StatsdDigestSampleRate(),
+		mtu:        cfg.StatsdMTU(),
+		avgRead:    cfg.AvgRowRead(),
+		avgSent:    cfg.AvgRowSent(),
+	}
+	if s.mtu <= 0 {
+		s.mtu = 1432
+	}
+	if s.sampleRate <= 0 || s.sampleRate > 1 {
+		s.sampleRate = 1
+	}
+	keys := make([]string, 0, len(cfg.StatsdTags()))
+	for k := range cfg.StatsdTags() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s.tags += "," + statsdTag(k, cfg.StatsdTags()[k])
+	}
+	return s, nil
+}
+
+func (s *statsdEmitter) ObserveInterval(t tick) {
+	type totals struct{ count, exam, sent, aff uint64 }
+	schemas := make(map[string]*totals)
+	stats := make([]digestStat, 0, len(t.Delta))
+	for _, d := range t.Delta {
+		stats = append(stats, d)
+		st := schemas[d.Schema]
+		if st == nil {
+			st = &totals{}
+			schemas[d.Schema] = st
+		}
+		st.count += d.CountStar
+		st.exam += d.SumRowsExam
+		st.sent += d.SumRowsSent
+		st.aff += d.SumRowsAff
+	}
+
+	names := make([]string, 0, len(schemas))
+	for n := range schemas {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		st := schemas[n]
+		tags := []string{"schema", n}
+		s.counter("schema.executions", st.count, 1, tags...)
+		s.counter("schema.rows_examined", st.exam, 1, tags...)
+		s.counter("schema.rows_sent", st.sent, 1, tags...)
+		s.counter("schema.rows_affected", st.aff, 1, tags...)
+		s.counter("schema.est_read_bytes", st.exam*s.avgRead, 1, tags...)
+		s.counter("schema.est_write_bytes", st.sent*s.avgSent, 1, tags...)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].SumRowsExam*s.avgRead+stats[i].SumRowsSent*s.avgSent > stats[j].SumRowsExam*s.avgRead+stats[j].SumRowsSent*s.avgSent
+	})
+	if s.topN >= 0 && len(stats) > s.topN {
+		stats = stats[:s.topN]
+	}
+	for _, d := range stats {
+		if s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+			continue
+		}
+		tags := []string{"schema", d.Schema, "digest", d.Digest}
+		s.counter("digest.executions", d.CountStar, s.sampleRate, tags...)
+		s.counter("digest.rows_examined", d.SumRowsExam, s.sampleRate, tags...)
+		s.counter("digest.rows_sent", d.SumRowsSent, s.sampleRate, tags...)
+		s.counter("digest.rows_affected", d.SumRowsAff, s.sampleRate, tags...)
+		s.counter("digest.est_read_bytes", d.SumRowsExam*s.avgRead, s.sampleRate, tags...)
+		s.counter("digest.est_write_bytes", d.SumRowsSent*s.avgSent, s.sampleRate, tags...)
+	}
+
+	if t.Engine != nil {
+		s.counter("engine.data_read_bytes", t.Engine.Delta.Read, 1)
+		s.counter("engine.data_written_bytes", t.Engine.Delta.Written, 1)
+	}
+	s.counter("alerts", uint64(len(t.Alerts)), 1)
+	s.metric("digests.active", strconv.Itoa(len(t.Delta)), "g", 1)
+	s.metric("snapshot.duration", strconv.FormatInt(t.Took.Milliseconds(), 10), "ms", 1)
+	s.flush()
+}
+
+func (s *statsdEmitter) counter(name string, v uint64, rate float64, tags ...string) {
+	s.metric(name, strconv.FormatUint(v, 10), "c", rate, tags...)
+}
+
+// metric renders one line. Plain StatsD has no tags, so tag values become name segments.
+func (s *statsdEmitter) metric(name, value, typ string, rate float64, tags ...string) {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	if s.tagged {
+		b.WriteString(name)
+	} else {
+		group, leaf, _ := strings.Cut(name, ".")
+		b.WriteString(group)
+		for i := 1; i < len(tags); i += 2 {
+			b.WriteByte('.')
+			b.WriteString(statsdSegment(tags[i]))
+		}
+		if leaf != "" {
+			b.WriteByte('.')
+			b.WriteString(leaf)
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if rate < 1 {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(rate, 'g', -1, 64))
+	}
+	if s.tagged {
+		var t strings.Builder
+		for i := 0; i+1 < len(tags); i += 2 {
+			t.WriteByte(',')
+			t.WriteString(statsdTag(tags[i], tags[i+1]))
+		}
+		t.WriteString(s.tags)
+		if t.Len() > 0 {
+			b.WriteString("|#")
+			b.WriteString(t.String()[1:])
+		}
+	}
+	s.write(b.String())
+}
+
+// write appends line to the current packet, sending the packet first if the line would not fit.
+func (s *statsdEmitter) write(line string) {
+	if s.packet.Len() > 0 && s.packet.Len()+1+len(line) > s.mtu {
+		s.flush()
+	}
+	if s.packet.Len() > 0 {
+		s.packet.WriteByte('\n')
+	}
+	s.packet.WriteString(line)
+}
+
+func (s *statsdEmitter) flush() {
+	if s.packet.Len() == 0 {
+		return
+	}
+	if _, err := s.conn.Write([]byte(s.packet.String())); err != nil {
+		s.log.Debug("statsd send failed", "err", err)
+	}
+	s.packet.Reset()
+}
+
+func (s *statsdEmitter) Close() error { return s.conn.Close() }
+
+// statsdSegment makes v safe as a dot-separated name segment.
+func statsdSegment(v string) string {
+	if v == "" {
+		return "none"
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 && r != '$' && isIdentByte(byte(r)) || r == '-' {
+			return r
+		}
+		return '_'
+	}, v)
+}
+
+// statsdTag renders a DogStatsD tag; ',', '|' and '#' would break the line format.
+func statsdTag(k, v string) string {
+	r := strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+	if v == "" {
+		v = "none"
+	}
+	return r.Replace(k) + ":" + r.Replace(v)
+}
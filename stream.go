@@ -10,26 +10,35 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Toggle to disable the broadcaster/subscribe mechanism and use a simple direct streaming mode.
 var sseNoBus bool
 
-// Minimal in-memory ring buffer to keep recent log lines for direct streaming mode.
+// Minimal in-memory ring buffer to keep recent log lines for direct streaming mode
+// and for replaying to reconnecting clients. Sequence numbers start at 1 and
+// double as SSE event ids.
 type logRing struct {
-	mu   sync.Mutex
-	cap  int
-	data []string
-	base uint64 // seq number of data[0]
-	next uint64 // next seq to assign
+	mu      sync.Mutex
+	cap     int
+	data    []string
+	base    uint64        // seq number of data[0]
+	next    uint64        // next seq to assign
+	changed chan struct{} // closed and replaced on every Append
 }
 
-func newLogRing(capacity int) *logRing { return &logRing{cap: capacity, data: make([]string, 0, capacity)} }
+func newLogRing(capacity int) *logRing {
+	capacity = max(capacity, 1)
+	return &logRing{cap: capacity, data: make([]string, 0, capacity), base: 1, next: 1, changed: make(chan struct{})}
+}
 
-func (r *logRing) Append(s string) {
+// Append stores s and returns its sequence number.
+func (r *logRing) Append(s string) uint64 {
 	r.mu.Lock()
 	if len(r.data) == r.cap {
 		// drop oldest
@@ -37,34 +46,105 @@ func (r *logRing) Append(s string) {
 		r.base++
 	}
 	r.data = append(r.data, s)
+	seq := r.next
 	r.next++
+	close(r.changed)
+	r.changed = make(chan struct{})
 	r.mu.Unlock()
+	return seq
 }
 
-// GetFrom returns all lines with sequence >= seq.
-// It clamps seq to the current base if too old. It returns the new next sequence marker.
-func (r *logRing) GetFrom(seq uint64) ([]string, uint64) {
+// Changed returns a channel closed by the next Append. Take it before reading,
+// so a line appended in between is not missed.
+func (r *logRing) Changed() <-chan struct{} { r.mu.Lock(); defer r.mu.Unlock(); return r.changed }
+
+// GetFrom returns all lines with sequence >= seq and the sequence of the first
+// one. It clamps seq to the current base if too old, so first > seq means lines
+// were evicted. It returns the new next sequence marker.
+func (r *logRing) GetFrom(seq uint64) ([]string, uint64, uint64) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if seq < r.base {
 		seq = r.base
 	}
 	if seq > r.next {
-		return nil, r.next
+		return nil, r.next, r.next
 	}
 	startIdx := int(seq - r.base)
 	if startIdx < 0 || startIdx > len(r.data) {
 		startIdx = len(r.data)
 	}
 	out := append([]string(nil), r.data[startIdx:]...)
-	return out, r.next
+	return out, seq, r.next
 }
 
 // Head returns the current next sequence marker (i.e., position after the last element).
 func (r *logRing) Head() uint64 { r.mu.Lock(); defer r.mu.Unlock(); return r.next }
 
+// globalLogRing is replaced by main with one of MON_SSE_LOG_RING lines before logging starts.
 var globalLogRing = newLogRing(2048)
 
+// SSE client accounting for /logs and /events, exported via /metrics.
+var (
+	sseClients  atomic.Int64  // currently connected (both modes)
+	sseRejected atomic.Uint64 // refused because MON_SSE_MAX_CLIENTS was reached
+	sseDropped  atomic.Uint64 // messages a client lost because it fell behind
+	sseEvicted  atomic.Uint64 // clients disconnected for staying behind past MON_SSE_SLOW_TIMEOUT
+)
+
+// sseAcquire counts a new SSE client unless limit (> 0) clients are already
+// connected. Callers release with sseClients.Add(-1).
+func sseAcquire(limit int) bool {
+	for {
+		n := sseClients.Load()
+		if limit > 0 && n >= int64(limit) {
+			sseRejected.Add(1)
+			return false
+		}
+		if sseClients.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// sseReject answers a client over the subscriber cap.
+func sseReject(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(sseRetry.Seconds())))
+	http.Error(w, "too many stream clients", http.StatusServiceUnavailable)
+}
+
+// sseBacklog tracks how far one client has fallen behind: messages lost since
+// the last one it got, and since when it has been losing them.
+type sseBacklog struct {
+	dropped uint64
+	since   time.Time // zero while the client keeps up
+}
+
+// lost records n messages the client missed at now. It reports whether the
+// client has been losing messages for longer than limit (0 = no limit).
+func (b *sseBacklog) lost(n uint64, now time.Time, limit time.Duration) bool {
+	sseDropped.Add(n)
+	b.dropped += n
+	if b.since.IsZero() {
+		b.since = now
+	}
+	return limit > 0 && now.Sub(b.since) > limit
+}
+
+// caughtUp is called once a message reached the client; it returns the number
+// lost just before it.
+func (b *sseBacklog) caughtUp() uint64 {
+	n := b.dropped
+	b.dropped, b.since = 0, time.Time{}
+	return n
+}
+
+// writeSSEDropped tells a client that n messages were lost because it read too slowly.
+func writeSSEDropped(bw *bufio.Writer, n uint64) error {
+	_, err := fmt.Fprintf(bw, "event: dropped\ndata: {\"dropped\":%d}\n\n", n)
+	return err
+}
+
 func init() {
 	// Allow disabling the bus via env: MON_SSE_NO_BUS = 1|true|yes|on
 	v := os.Getenv("MON_SSE_NO_BUS")
@@ -77,29 +157,39 @@ func init() {
 // test hook: allow tests to toggle mode without relying on env parsing order.
 func sseSetNoBusForTests(v bool) { sseNoBus = v }
 
+// logLine is one log line with its ring sequence number.
+type logLine struct {
+	ID      uint64
+	Text    string
+	Dropped uint64 // lines this subscriber lost just before this one
+}
+
 // LogStreamBroadcaster abstracts broadcasting of log lines to subscribers.
 type LogStreamBroadcaster interface {
-	Subscribe() (chan string, func())
-	Broadcast(message string)
+	Subscribe() (chan logLine, func())
+	Broadcast(message logLine)
 }
 
 // sseBroadcaster is the unexported implementation of LogStreamBroadcaster.
 type sseBroadcaster struct {
+	cfg  Config
 	mu   sync.Mutex
-	subs map[chan string]struct{}
+	subs map[chan logLine]*sseBacklog
 }
 
-// NewLogStreamBroadcaster constructs a LogStreamBroadcaster.
-func NewLogStreamBroadcaster() LogStreamBroadcaster {
-	return &sseBroadcaster{subs: make(map[chan string]struct{})}
+// NewLogStreamBroadcaster constructs a LogStreamBroadcaster with MON_SSE_BUFFER
+// lines queued per subscriber.
+func NewLogStreamBroadcaster(cfg Config) LogStreamBroadcaster {
+	return &sseBroadcaster{cfg: cfg, subs: make(map[chan logLine]*sseBacklog)}
 }
 
-// Subscribe registers a new subscriber channel. The caller should consume promptly.
+// Subscribe registers a new subscriber channel. The caller should consume promptly:
+// the channel is closed if it keeps overflowing for MON_SSE_SLOW_TIMEOUT.
 // Returns the channel and an unsubscribe function.
-func (b *sseBroadcaster) Subscribe() (chan string, func()) {
-	ch := make(chan string, 256)
+func (b *sseBroadcaster) Subscribe() (chan logLine, func()) {
+	ch := make(chan logLine, max(b.cfg.SSEBuffer(), 1))
 	b.mu.Lock()
-	b.subs[ch] = struct{}{}
+	b.subs[ch] = &sseBacklog{}
 	b.mu.Unlock()
 	return ch, func() {
 		b.mu.Lock()
@@ -111,14 +201,24 @@ func (b *sseBroadcaster) Subscribe() (chan string, func()) {
 	}
 }
 
-// Broadcast sends a message to all subscribers, dropping if a channel is full.
-func (b *sseBroadcaster) Broadcast(message string) {
+// Broadcast sends a message to all subscribers. A subscriber whose channel is
+// full loses it; the next line it gets carries the count, and one that stays
+// full too long is closed. It must not log: it runs inside the log writer.
+func (b *sseBroadcaster) Broadcast(message logLine) {
+	now := time.Now()
+	limit := b.cfg.SSESlowTimeout()
 	b.mu.Lock()
-	for ch := range b.subs {
+	for ch, backlog := range b.subs {
+		message.Dropped = backlog.dropped
 		select {
 		case ch <- message:
+			backlog.caughtUp()
 		default:
-			// drop if slow consumer
+			if backlog.lost(1, now, limit) {
+				delete(b.subs, ch)
+				close(ch)
+				sseEvicted.Add(1)
+			}
 		}
 	}
 	b.mu.Unlock()
@@ -161,9 +261,9 @@ func (t *logTeeWriter) Write(p []byte) (int, error) {
 		}
 		if line != "" {
 			// Always append to ring for direct mode or late subscribers
-			globalLogRing.Append(line)
+			seq := globalLogRing.Append(line)
 			if !sseNoBus && t.bus != nil {
-				t.bus.Broadcast(line)
+				t.bus.Broadcast(logLine{ID: seq, Text: line})
 			}
 		}
 		// advance buffer
@@ -172,10 +272,56 @@ func (t *logTeeWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// sseRetry is the reconnection delay suggested to clients in the retry: field.
+const sseRetry = 3 * time.Second
+
+// sseResumeFrom returns the first ring sequence a reconnecting client still
+// needs: the one after the Last-Event-ID header, or after ?since=<id>
+// (since=0 replays everything retained). ok is false for a fresh client.
+func sseResumeFrom(r *http.Request) (seq uint64, ok bool, err error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("since")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid event id %q", v)
+	}
+	return id + 1, true, nil
+}
+
+// writeSSELine writes one log line as an SSE event carrying its ring id.
+func writeSSELine(bw *bufio.Writer, id uint64, line string) error {
+	_, err := fmt.Fprintf(bw, "id: %d\ndata: %s\n\n", id, line)
+	return err
+}
+
+// writeSSEGap tells a resuming client that the lines from requested up to
+// oldest-1 were evicted from the ring and cannot be replayed.
+func writeSSEGap(bw *bufio.Writer, requested, oldest uint64) error {
+	_, err := fmt.Fprintf(bw, "event: gap\ndata: {\"requested\":%d,\"oldest\":%d,\"missed\":%d}\n\n", requested, oldest, oldest-requested)
+	return err
+}
+
 // LogsSSEHandler streams logs via Server-Sent Events (SSE).
-// URL: /logs
-func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.HandlerFunc {
+// URL: /logs, optionally filtered server-side (see sseFilter), e.g. /logs?level=WARN&schema=billing
+// At most MON_SSE_MAX_CLIENTS streams (including /events) are served at once.
+func LogsSSEHandler(cfg Config, broadcaster LogStreamBroadcaster, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseSSEFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resumeFrom, resume, err := sseResumeFrom(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Headers for SSE and to disable buffering
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
@@ -188,10 +334,16 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
 			return
 		}
+		if !sseAcquire(cfg.SSEMaxClients()) {
+			logger.Warn("sse client rejected, too many clients", "remote", r.RemoteAddr, "path", r.URL.Path, "limit", cfg.SSEMaxClients())
+			sseReject(w)
+			return
+		}
+		defer sseClients.Add(-1)
 
 		// If broadcaster is disabled, use direct ring-buffer streaming mode.
 		if sseNoBus {
-			logger.Info("sse client connected (direct mode)", "remote", r.RemoteAddr, "path", r.URL.Path)
+			logger.Info("sse client connected (direct mode)", "remote", r.RemoteAddr, "path", r.URL.Path, "filtered", filter.active())
 			defer logger.Info("sse client disconnected (direct mode)", "remote", r.RemoteAddr, "path", r.URL.Path)
 
 			// Heartbeat interval (default 15s). Allow override for tests via query param "heartbeat".
@@ -208,10 +360,9 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 			}
 			hb := time.NewTicker(heartbeat)
 			defer hb.Stop()
-			poll := time.NewTicker(200 * time.Millisecond)
-			defer poll.Stop()
 
 			// Initial hello event
+			fmt.Fprintf(w, "retry: %d\n", sseRetry.Milliseconds())
 			fmt.Fprintf(w, "event: hello\n")
 			fmt.Fprintf(w, "data: %s\n\n", "{\"msg\":\"connected\"}")
 			flusher.Flush()
@@ -219,7 +370,36 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 			notify := r.Context().Done()
 			bw := bufio.NewWriter(w)
 			seq := globalLogRing.Head()
+			if resume && resumeFrom < seq {
+				seq = resumeFrom
+			}
+			// Lines evicted before this client read them count as lost, unless
+			// they were already gone when it asked to resume
+			resumed := resume
+			var backlog sseBacklog
 			for {
+				changed := globalLogRing.Changed()
+				lines, first, next := globalLogRing.GetFrom(seq)
+				if first > seq {
+					if err := writeSSEGap(bw, seq, first); err != nil { return }
+					if !resumed && backlog.lost(first-seq, time.Now(), cfg.SSESlowTimeout()) {
+						sseEvicted.Add(1)
+						logger.Warn("sse client too slow, disconnected (direct mode)", "remote", r.RemoteAddr, "path", r.URL.Path, "dropped", backlog.dropped)
+						return
+					}
+				} else if len(lines) > 0 {
+					backlog.caughtUp()
+				}
+				resumed = false
+				for i, line := range lines {
+					if !filter.match(line) { continue }
+					if err := writeSSELine(bw, first+uint64(i), line); err != nil { return }
+				}
+				if len(lines) > 0 || first > seq {
+					if err := bw.Flush(); err != nil { return }
+					flusher.Flush()
+				}
+				seq = next
 				select {
 				case <-notify:
 					return
@@ -227,21 +407,12 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 					if _, err := bw.WriteString(": keepalive\n\n"); err != nil { return }
 					if err := bw.Flush(); err != nil { return }
 					flusher.Flush()
-				case <-poll.C:
-					lines, next := globalLogRing.GetFrom(seq)
-					for _, line := range lines {
-						if _, err := bw.WriteString("data: "); err != nil { return }
-						if _, err := bw.WriteString(line); err != nil { return }
-						if _, err := bw.WriteString("\n\n"); err != nil { return }
-						if err := bw.Flush(); err != nil { return }
-						flusher.Flush()
-					}
-					seq = next
+				case <-changed:
 				}
 			}
 		}
 
-		logger.Info("sse client connected", "remote", r.RemoteAddr, "path", r.URL.Path)
+		logger.Info("sse client connected", "remote", r.RemoteAddr, "path", r.URL.Path, "filtered", filter.active())
 		defer logger.Info("sse client disconnected", "remote", r.RemoteAddr, "path", r.URL.Path)
 
 		ch, unsubscribe := broadcaster.Subscribe()
@@ -263,7 +434,8 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 		ticker := time.NewTicker(heartbeat)
 		defer ticker.Stop()
 
-		// Send a hello event so clients know the stream is up
+		// Send a hello event so clients know the stream is up, with the reconnect delay
+		fmt.Fprintf(w, "retry: %d\n", sseRetry.Milliseconds())
 		fmt.Fprintf(w, "event: hello\n")
 		fmt.Fprintf(w, "data: %s\n\n", "{\"msg\":\"connected\"}")
 		flusher.Flush()
@@ -271,6 +443,30 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 		// Use a buffered writer pattern to loop until client disconnects
 		notify := r.Context().Done()
 		bw := bufio.NewWriter(w)
+
+		// Replay what a reconnecting client missed. We subscribed first, so lines
+		// arriving meanwhile are both replayed and queued; the queue skips ids already sent.
+		var lastID uint64
+		if resume {
+			lines, first, next := globalLogRing.GetFrom(resumeFrom)
+			if first > resumeFrom {
+				if err := writeSSEGap(bw, resumeFrom, first); err != nil {
+					return
+				}
+			}
+			for i, line := range lines {
+				if filter.match(line) {
+					if err := writeSSELine(bw, first+uint64(i), line); err != nil {
+						return
+					}
+				}
+			}
+			if err := bw.Flush(); err != nil {
+				return
+			}
+			flusher.Flush()
+			lastID = next - 1
+		}
 		for {
 			select {
 			case <-notify:
@@ -286,16 +482,29 @@ func LogsSSEHandler(broadcaster LogStreamBroadcaster, logger *slog.Logger) http.
 				flusher.Flush()
 			case line, ok := <-ch:
 				if !ok {
+					// Only the broadcaster closes it early: this client stayed behind too long
+					logger.Warn("sse client too slow, disconnected", "remote", r.RemoteAddr, "path", r.URL.Path)
 					return
 				}
-				// SSE format: data: <json>\n\n
-				if _, err := bw.WriteString("data: "); err != nil {
-					return
+				if line.ID <= lastID {
+					continue
 				}
-				if _, err := bw.WriteString(line); err != nil {
-					return
+				if line.Dropped > 0 {
+					if err := writeSSEDropped(bw, line.Dropped); err != nil {
+						return
+					}
+				}
+				if !filter.match(line.Text) {
+					if line.Dropped > 0 {
+						if err := bw.Flush(); err != nil {
+							return
+						}
+						flusher.Flush()
+					}
+					continue
 				}
-				if _, err := bw.WriteString("\n\n"); err != nil {
+				// SSE format: id: <seq>\ndata: <json>\n\n
+				if err := writeSSELine(bw, line.ID, line.Text); err != nil {
 					return
 				}
 				if err := bw.Flush(); err != nil {
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRingGetFrom(t *testing.T) {
+	// Capacity 3 after 5 appends: lines 3..5 are retained, 6 is next
+	r := newLogRing(3)
+	for _, s := range []string{"l1", "l2", "l3", "l4", "l5"} {
+		r.Append(s)
+	}
+	for _, tc := range []struct {
+		from        uint64
+		lines       []string
+		first, next uint64
+	}{
+		{0, []string{"l3", "l4", "l5"}, 3, 6},
+		{1, []string{"l3", "l4", "l5"}, 3, 6}, // first > from: 1 and 2 were evicted
+		{3, []string{"l3", "l4", "l5"}, 3, 6},
+		{5, []string{"l5"}, 5, 6},
+		{6, nil, 6, 6}, // caught up
+		{9, nil, 6, 6}, // ahead of the ring, e.g. an id from before a restart
+	} {
+		lines, first, next := r.GetFrom(tc.from)
+		if len(lines) == 0 {
+			lines = nil
+		}
+		if !reflect.DeepEqual(lines, tc.lines) || first != tc.first || next != tc.next {
+			t.Errorf("GetFrom(%d) = %v, %d, %d; want %v, %d, %d", tc.from, lines, first, next, tc.lines, tc.first, tc.next)
+		}
+	}
+}
+
+func TestSSEResumeFrom(t *testing.T) {
+	for _, tc := range []struct {
+		name, lastID, query string
+		seq                 uint64
+		ok, err             bool
+	}{
+		{name: "fresh client"},
+		{name: "last event id", lastID: "7", seq: 8, ok: true},
+		{name: "padded id", lastID: " 7 ", seq: 8, ok: true},
+		{name: "since", query: "since=41", seq: 42, ok: true},
+		{name: "since zero replays all", query: "since=0", seq: 1, ok: true},
+		{name: "header wins", lastID: "3", query: "since=41", seq: 4, ok: true},
+		{name: "invalid header", lastID: "abc", err: true},
+		{name: "negative since", query: "since=-1", err: true},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/logs?"+tc.query, nil)
+		if tc.lastID != "" {
+			r.Header.Set("Last-Event-ID", tc.lastID)
+		}
+		seq, ok, err := sseResumeFrom(r)
+		if (err != nil) != tc.err || seq != tc.seq || ok != tc.ok {
+			t.Errorf("%s: got %d, %v, %v; want %d, %v, err=%v", tc.name, seq, ok, err, tc.seq, tc.ok, tc.err)
+		}
+	}
+}
+
+func TestSSEBacklog(t *testing.T) {
+	var b sseBacklog
+	now := time.Now()
+	if b.lost(2, now, time.Second) {
+		t.Fatal("evicted on the first loss")
+	}
+	if b.lost(1, now.Add(900*time.Millisecond), time.Second) {
+		t.Fatal("evicted before the slow timeout passed")
+	}
+	if n := b.caughtUp(); n != 3 {
+		t.Fatalf("caughtUp = %d, want 3 lost", n)
+	}
+	// Catching up restarts the clock
+	if b.lost(1, now.Add(2*time.Second), time.Second) {
+		t.Fatal("evicted right after catching up")
+	}
+	if !b.lost(1, now.Add(3500*time.Millisecond), time.Second) {
+		t.Fatal("not evicted after falling behind for longer than the timeout")
+	}
+	var unlimited sseBacklog
+	if unlimited.lost(1, now, 0) || unlimited.lost(1, now.Add(time.Hour), 0) {
+		t.Fatal("evicted without a slow timeout")
+	}
+}
+
+// readSSE collects "id:" and "event:" lines from an SSE body until stop
+// returns true for one of them.
+func readSSE(t *testing.T, url string, header http.Header, stop func(line string) bool) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "id: ") && !strings.HasPrefix(line, "event: ") {
+			continue
+		}
+		got = append(got, line)
+		if stop(line) {
+			return got
+		}
+	}
+	t.Fatalf("stream ended before the expected event, got %v", got)
+	return nil
+}
+
+func TestLogsSSEResumeAfterEviction(t *testing.T) {
+	ring := newLogRing(3)
+	for _, s := range []string{`{"msg":"a"}`, `{"msg":"b"}`, `{"msg":"c"}`, `{"msg":"d"}`, `{"msg":"e"}`} {
+		ring.Append(s)
+	}
+	oldRing := globalLogRing
+	globalLogRing = ring
+	sseSetNoBusForTests(true)
+	t.Cleanup(func() { globalLogRing = oldRing; sseSetNoBusForTests(false) })
+
+	srv := httptest.NewServer(LogsSSEHandler(testConfig(nil), NewLogStreamBroadcaster(testConfig(nil)), testLogger()))
+	defer srv.Close()
+
+	// Last saw 1: 2 was evicted, so a gap comes first, then 3..5 once each
+	got := readSSE(t, srv.URL, http.Header{"Last-Event-Id": {"1"}}, func(l string) bool { return l == "id: 5" })
+	want := []string{"event: hello", "event: gap", "id: 3", "id: 4", "id: 5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed stream = %v, want %v", got, want)
+	}
+
+	// Caught up: nothing is replayed, the next line is streamed live
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ring.Append(`{"msg":"f"}`)
+	}()
+	got = readSSE(t, srv.URL, http.Header{"Last-Event-Id": {"5"}}, func(l string) bool { return strings.HasPrefix(l, "id: ") })
+	if want := []string{"event: hello", "id: 6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("caught-up stream = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// summaryGenerator accumulates interval deltas over calendar periods (hour,
+// day or week, local time) and, when a period ends, writes a summary report
+// with totals per schema and per statement type plus the top digests with
+// samples, as Markdown, standalone HTML and/or CSV. Finished reports are also
+// handed to reporters implementing summaryReporter. Open periods are persisted
+// so a restart does not lose them.
+type summaryGenerator struct {
+	log        *slog.Logger
+	reporter   Reporter // nil unless MON_SUMMARY_NOTIFY
+	dir        string
+	formats    []string
+	topN       int
+	target     string
+	avgRowRead uint64
+	avgRowSent uint64
+
+	windows map[string]*summaryWindow // by period
+	dirty   bool
+	savedAt time.Time
+}
+
+// summaryReporter is implemented by reporters that want finished summary reports.
+type summaryReporter interface {
+	Summary(r *summaryReport)
+}
+
+// summaryWindow is the persisted accumulator of one period.
+type summaryWindow struct {
+	Period    string                    `json:"period"`
+	Start     time.Time                 `json:"start"`
+	Intervals int                       `json:"intervals"`
+	Digests   map[string]*summaryDigest `json:"digests"` // by snapKey
+}
+
+// summaryTotals is one row of a totals table.
+type summaryTotals struct {
+	Name         string `json:"name,omitempty"`
+	Digests      int    `json:"digests"`
+	Count        uint64 `json:"count"`
+	RowsExamined uint64 `json:"rowsExamined"`
+	RowsSent     uint64 `json:"rowsSent"`
+	RowsAffected uint64 `json:"rowsAffected"`
+	BytesRead    uint64 `json:"bytesRead"`
+	BytesWrite   uint64 `json:"bytesWrite"`
+}
+
+type summaryDigest struct {
+	Schema    string `json:"schema"`
+	Digest    string `json:"digest"`
+	Statement string `json:"statement"`
+	Sample    string `json:"sample"`
+	summaryTotals
+}
+
+// summaryReport is a finished period, as rendered and as passed to reporters.
+type summaryReport struct {
+	Period     string
+	Start      time.Time
+	End        time.Time
+	Target     string
+	Intervals  int
+	Total      summaryTotals
+	Schemas    []summaryTotals
+	Statements []summaryTotals
+	Top        []summaryDigest
+	Files      []string // written report files
+}
+
+type summaryState struct {
+	Version int              `json:"version"`
+	Windows []*summaryWindow `json:"windows"`
+}
+
+const (
+	summarySaveInterval = time.Minute
+	summaryStateName    = "summary-state.json"
+)
+
+var summaryFormats = []string{"md", "html", "csv"}
+
+// newSummaryGenerator validates the settings, creates the report directory and
+// restores open periods. Periods that ended while the monitor was down are
+// reported on the first interval.
+func newSummaryGenerator(cfg Config, log *slog.Logger, reporter Reporter) (*summaryGenerator, error) {
+	if !cfg.SummaryNotify() {
+		reporter = nil
+	}
+	s := &summaryGenerator{
+		log:        log,
+		reporter:   reporter,
+		dir:        cfg.SummaryDir(),
+		topN:       cfg.SummaryTop(),
+		target:     dsnTarget(cfg.DSN()),
+		avgRowRead: cfg.AvgRowRead(),
+		avgRowSent: cfg.AvgRowSent(),
+		windows:    make(map[string]*summaryWindow),
+	}
+	for _, p := range cfg.SummaryPeriods() {
+		switch p = strings.ToLower(p); p {
+		case "hour", "day", "week":
+			s.windows[p] = nil
+		default:
+			return nil, fmt.Errorf("summary period %q: want hour|day|week", p)
+		}
+	}
+	for _, f := range cfg.SummaryFormats() {
+		f = strings.ToLower(f)
+		if !containsString(summaryFormats, f) {
+			return nil, fmt.Errorf("summary format %q: want md|html|csv", f)
+		}
+		s.formats = append(s.formats, f)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, summaryStateName))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st summaryState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", summaryStateName, err)
+	}
+	for _, w := range st.Windows {
+		if _, ok := s.windows[w.Period]; ok && w.Digests != nil {
+			s.windows[w.Period] = w
+		}
+	}
+	return s, nil
+}
+
+// summaryWindowStart returns the start of the calendar hour/day/week (Monday) containing t.
+func summaryWindowStart(period string, t time.Time) time.Time {
+	switch period {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case "week":
+		back := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(t.Year(), t.Month(), t.Day()-back, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+func summaryWindowEnd(period string, start time.Time) time.Time {
+	switch period {
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func (s *summaryGenerator) ObserveInterval(t tick) {
+	for _, p := range sortedKeys(s.windows) {
+		w := s.windows[p]
+		start := summaryWindowStart(p, t.At)
+		if w != nil && !w.Start.Equal(start) {
+			if w.Intervals > 0 {
+				s.finish(w)
+			}
+			w = nil
+		}
+		if w == nil {
+			w = &summaryWindow{Period: p, Start: start, Digests: make(map[string]*summaryDigest)}
+			s.windows[p] = w
+		}
+		w.Intervals++
+		for k, d := range t.Delta {
+			sd := w.Digests[string(k)]
+			if sd == nil {
+				sd = &summaryDigest{Schema: d.Schema, Digest: d.Digest, Statement: statementType(d.DigestText)}
+				w.Digests[string(k)] = sd
+			}
+			if text := sampleText(d); text != "" {
+				sd.Sample = text
+			}
+			sd.Count += d.CountStar
+			sd.RowsExamined += d.SumRowsExam
+			sd.RowsSent += d.SumRowsSent
+			sd.RowsAffected += d.SumRowsAff
+			sd.BytesRead += d.SumRowsExam * s.avgRowRead
+			sd.BytesWrite += d.SumRowsSent * s.avgRowSent
+		}
+	}
+	s.dirty = true
+	if t.At.Sub(s.savedAt) >= summarySaveInterval {
+		if err := s.save(); err != nil {
+			s.log.Error("summary state save", "dir", s.dir, "err", err)
+		}
+		s.savedAt = t.At
+	}
+}
+
+// finish renders and writes the report for a closed window and passes it on.
+func (s *summaryGenerator) finish(w *summaryWindow) {
+	r := s.report(w)
+	base := filepath.Join(s.dir, fmt.Sprintf("summary-%s-%s", w.Period, w.Start.Format("2006-01-02T15")))
+	for _, f := range s.formats {
+		var buf bytes.Buffer
+		var err error
+		switch f {
+		case "md":
+			err = writeSummaryMarkdown(&buf, r)
+		case "html":
+			err = summaryHTML.Execute(&buf, r)
+		case "csv":
+			err = writeSummaryCSV(&buf, r)
+		}
+		if err == nil {
+			err = writeFileAtomic(base+"."+f, buf.Bytes())
+		}
+		if err != nil {
+			s.log.Error("summary report write", "file", base+"."+f, "err", err)
+			continue
+		}
+		r.Files = append(r.Files, base+"."+f)
+	}
+	s.log.Info("summary report written",
+		"period", r.Period,
+		"start", r.Start.Format(time.RFC3339),
+		"end", r.End.Format(time.RFC3339),
+		"digests", r.Total.Digests,
+		"count", r.Total.Count,
+		"bytesRead", bytesToHuman(r.Total.BytesRead),
+		"bytesWrite", bytesToHuman(r.Total.BytesWrite),
+		"files", r.Files,
+	)
+	if sr, ok := s.reporter.(summaryReporter); ok {
+		sr.Summary(r)
+	}
+}
+
+// report aggregates a window into totals per schema and statement type and the top digests.
+func (s *summaryGenerator) report(w *summaryWindow) *summaryReport {
+	r := &summaryReport{Period: w.Period, Start: w.Start, End: summaryWindowEnd(w.Period, w.Start), Target: s.target, Intervals: w.Intervals}
+	schemas := make(map[string]*summaryTotals)
+	statements := make(map[string]*summaryTotals)
+	digests := make([]summaryDigest, 0, len(w.Digests))
+	for _, d := range w.Digests {
+		digests = append(digests, *d)
+		for _, t := range []*summaryTotals{&r.Total, summaryBucket(schemas, d.Schema), summaryBucket(statements, d.Statement)} {
+			t.Digests++
+			t.Count += d.Count
+			t.RowsExamined += d.RowsExamined
+			t.RowsSent += d.RowsSent
+			t.RowsAffected += d.RowsAffected
+			t.BytesRead += d.BytesRead
+			t.BytesWrite += d.BytesWrite
+		}
+	}
+	r.Schemas = sortedSummaryTotals(schemas)
+	r.Statements = sortedSummaryTotals(statements)
+	sort.Slice(digests, func(i, j int) bool {
+		return digests[i].BytesRead+digests[i].BytesWrite > digests[j].BytesRead+digests[j].BytesWrite
+	})
+	if s.topN >= 0 && len(digests) > s.topN {
+		digests = digests[:s.topN]
+	}
+	r.Top = digests
+	return r
+}
+
+func summaryBucket(m map[string]*summaryTotals, name string) *summaryTotals {
+	t := m[name]
+	if t == nil {
+		t = &summaryTotals{Name: name}
+		m[name] = t
+	}
+	return t
+}
+
+// sortedSummaryTotals orders rows by estimated bytes, largest first.
+func sortedSummaryTotals(m map[string]*summaryTotals) []summaryTotals {
+	out := make([]summaryTotals, 0, len(m))
+	for _, t := range m {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].BytesRead+out[i].BytesWrite, out[j].BytesRead+out[j].BytesWrite
+		if a != b {
+			return a > b
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// statementType is the leading keyword of a normalized statement (SELECT, INSERT, ...).
+func statementType(digestText string) string {
+	f := strings.Fields(strings.TrimLeft(digestText, "( "))
+	if len(f) == 0 {
+		return "OTHER"
+	}
+	kw := strings.ToUpper(strings.TrimRight(f[0], "(;"))
+	for _, c := range kw {
+		if c < 'A' || c > 'Z' {
+			return "OTHER"
+		}
+	}
+	return kw
+}
+
+// Close saves open windows; they are continued (or reported) on the next start.
+func (s *summaryGenerator) Close() error {
+	if !s.dirty {
+		return nil
+	}
+	return s.save()
+}
+
+func (s *summaryGenerator) save() error {
+	st := summaryState{Version: 1}
+	for _, p := range sortedKeys(s.windows) {
+		if w := s.windows[p]; w != nil {
+			st.Windows = append(st.Windows, w)
+		}
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, summaryStateName), data); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+func writeSummaryMarkdown(buf *bytes.Buffer, r *summaryReport) error {
+	fmt.Fprintf(buf, "# Query throughput summary (%s)\n\n", r.Period)
+	fmt.Fprintf(buf, "- Target: `%s`\n", r.Target)
+	fmt.Fprintf(buf, "- Period: %s – %s (%d intervals)\n", r.Start.Format("2006-01-02 15:04 MST"), r.End.Format("2006-01-02 15:04 MST"), r.Intervals)
+	fmt.Fprintf(buf, "- Total: %d executions of %d digests, est. read %s, est. write %s\n\n",
+		r.Total.Count, r.Total.Digests, bytesToHuman(r.Total.BytesRead), bytesToHuman(r.Total.BytesWrite))
+
+	totals := func(title, col string, rows []summaryTotals) {
+		fmt.Fprintf(buf, "## %s\n\n| %s | Digests | Executions | Rows examined | Rows sent | Rows affected | Est. read | Est. write |\n", title, col)
+		buf.WriteString("|---|---:|---:|---:|---:|---:|---:|---:|\n")
+		for _, t := range rows {
+			fmt.Fprintf(buf, "| %s | %d | %d | %d | %d | %d | %s | %s |\n", markdownCell(summaryName(t.Name)), t.Digests, t.Count,
+				t.RowsExamined, t.RowsSent, t.RowsAffected, bytesToHuman(t.BytesRead), bytesToHuman(t.BytesWrite))
+		}
+		buf.WriteString("\n")
+	}
+	totals("Per schema", "Schema", r.Schemas)
+	totals("Per statement type", "Statement", r.Statements)
+
+	fmt.Fprintf(buf, "## Top %d digests by estimated bytes\n\n", len(r.Top))
+	for i, d := range r.Top {
+		fmt.Fprintf(buf, "### %d. `%s` %s on %s\n\n", i+1, d.Digest, d.Statement, markdownCell(summaryName(d.Schema)))
+		fmt.Fprintf(buf, "%d executions, rows examined %d / sent %d / affected %d, est. read %s, est. write %s\n\n",
+			d.Count, d.RowsExamined, d.RowsSent, d.RowsAffected, bytesToHuman(d.BytesRead), bytesToHuman(d.BytesWrite))
+		fence := "```"
+		for strings.Contains(d.Sample, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(buf, "%ssql\n%s\n%s\n\n", fence, d.Sample, fence)
+	}
+	return nil
+}
+
+// summaryName shows digests run without a default schema as "(none)".
+func summaryName(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// writeSummaryCSV writes one table: a section column (total, schema,
+// statement, digest) followed by the same counters for every row.
+func writeSummaryCSV(buf *bytes.Buffer, r *summaryReport) error {
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"section", "name", "schema", "digest", "statement", "digests", "count", "rows_examined", "rows_sent", "rows_affected", "est_read_bytes", "est_write_bytes", "period_start", "period_end", "sample"})
+	row := func(section, name, schema, digest, statement, sample string, t summaryTotals) {
+		_ = w.Write([]string{section, name, schema, digest, statement,
+			strconv.Itoa(t.Digests), strconv.FormatUint(t.Count, 10),
+			strconv.FormatUint(t.RowsExamined, 10), strconv.FormatUint(t.RowsSent, 10), strconv.FormatUint(t.RowsAffected, 10),
+			strconv.FormatUint(t.BytesRead, 10), strconv.FormatUint(t.BytesWrite, 10),
+			r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), sample})
+	}
+	row("total", "", "", "", "", "", r.Total)
+	for _, t := range r.Schemas {
+		row("schema", t.Name, t.Name, "", "", "", t)
+	}
+	for _, t := range r.Statements {
+		row("statement", t.Name, "", "", t.Name, "", t)
+	}
+	for _, d := range r.Top {
+		t := d.summaryTotals
+		t.Digests = 1
+		row("digest", d.Digest, d.Schema, d.Digest, d.Statement, d.Sample, t)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var summaryHTML = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"bytes": bytesToHuman,
+	"time":  func(t time.Time) string { return t.Format("2006-01-02 15:04 MST") },
+	"inc":   func(i int) int { return i + 1 },
+	"name":  summaryName,
+	"dict": func(kv ...any) map[string]any {
+		m := make(map[string]any, len(kv)/2)
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[kv[i].(string)] = kv[i+1]
+		}
+		return m
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en"><head><meta charset="utf-8">
+<title>Query throughput summary ({{.Period}}) – {{.Target}}</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:2em;color:#222}
+table{border-collapse:collapse;margin-bottom:2em}
+th,td{border:1px solid #ccc;padding:4px 8px;text-align:right}
+th:first-child,td:first-child{text-align:left}
+th{background:#f3f3f3}
+pre{background:#f7f7f7;padding:8px;white-space:pre-wrap;word-break:break-all}
+.muted{color:#666}
+</style></head><body>
+<h1>Query throughput summary ({{.Period}})</h1>
+<p class="muted">Target <code>{{.Target}}</code> · {{time .Start}} – {{time .End}} · {{.Intervals}} intervals</p>
+<p>Total: {{.Total.Count}} executions of {{.Total.Digests}} digests, est. read {{bytes .Total.BytesRead}}, est. write {{bytes .Total.BytesWrite}}</p>
+{{define "totals"}}<table><tr><th>{{.Col}}</th><th>Digests</th><th>Executions</th><th>Rows examined</th><th>Rows sent</th><th>Rows affected</th><th>Est. read</th><th>Est. write</th></tr>
+{{range .Rows}}<tr><td>{{name .Name}}</td><td>{{.Digests}}</td><td>{{.Count}}</td><td>{{.RowsExamined}}</td><td>{{.RowsSent}}</td><td>{{.RowsAffected}}</td><td>{{bytes .BytesRead}}</td><td>{{bytes .BytesWrite}}</td></tr>
+{{end}}</table>{{end}}
+<h2>Per schema</h2>
+{{template "totals" (dict "Col" "Schema" "Rows" .Schemas)}}
+<h2>Per statement type</h2>
+{{template "totals" (dict "Col" "Statement" "Rows" .Statements)}}
+<h2>Top {{len .Top}} digests by estimated bytes</h2>
+{{range $i, $d := .Top}}<h3>{{inc $i}}. {{$d.Statement}} on {{name $d.Schema}} <code class="muted">{{$d.Digest}}</code></h3>
+<p>{{$d.Count}} executions, rows examined {{$d.RowsExamined}} / sent {{$d.RowsSent}} / affected {{$d.RowsAffected}}, est. read {{bytes $d.BytesRead}}, est. write {{bytes $d.BytesWrite}}</p>
+<pre>{{$d.Sample}}</pre>
+{{end}}</body></html>
+`))
+
+func containsString(xs []string, v string) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// `top` subcommand: a refreshing terminal table of the current interval's
+// digests. It takes its own snapshots (same filter and redaction as the
+// monitor, no reporters) and draws with plain ANSI escapes; the terminal is put
+// into raw mode with stty(1) and restored on exit.
+
+const (
+	topHistory   = 16 // intervals kept per digest for the sparkline
+	topColumns   = 8  // sortable columns, keys 1..8
+	topFooterFmt = "q quit  p pause  1-%d sort (again: reverse)  / schema filter  ↑↓/jk select  enter sample"
+)
+
+var (
+	topColumnNames = [topColumns]string{"DIGEST", "SCHEMA", "COUNT", "EXAMINED", "SENT", "AFFECTED", "EST BYTES", "RATE/s"}
+	topWidths      = [topColumns]int{14, 14, 9, 11, 9, 11, 12, 11}
+)
+
+// topRow is one digest in the current interval.
+type topRow struct {
+	key      snapKey
+	schema   string
+	digest   string
+	sample   string
+	count    uint64
+	examined uint64
+	sent     uint64
+	affected uint64
+	bytes    uint64 // estimated read + write
+	rate     float64
+}
+
+type topSample struct {
+	at      time.Time
+	elapsed time.Duration
+	delta   map[snapKey]digestStat
+	err     error
+}
+
+type topView struct {
+	target  string
+	avgRead uint64
+	avgSent uint64
+
+	rows     []topRow
+	history  map[snapKey][]uint64
+	at       time.Time
+	elapsed  time.Duration
+	err      error
+	sortCol  int // index into topColumnNames
+	asc      bool
+	paused   bool
+	schema   string  // schema filter, empty = all
+	prompt   *string // schema filter being typed
+	selected int
+	detail   bool
+	width    int
+	height   int
+}
+
+// runTop runs the interactive view until the user quits.
+func runTop(cfg Config) error {
+	restore, err := ttyRaw()
+	if err != nil {
+		return fmt.Errorf("top needs an interactive terminal: %w", err)
+	}
+	defer restore()
+
+	// Log lines would corrupt the screen; the view shows errors itself.
+	quiet := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	redactor, err := NewRedactor(cfg.RedactMode(), cfg.RedactPatterns())
+	if err != nil {
+		return err
+	}
+	filter, err := NewDigestFilter(cfg, quiet)
+	if err != nil {
+		return err
+	}
+	db, err := NewMySQLClient(cfg.DSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	v := &topView{
+		target:  dsnTarget(cfg.DSN()),
+		avgRead: cfg.AvgRowRead(),
+		avgSent: cfg.AvgRowSent(),
+		history: make(map[snapKey][]uint64),
+		sortCol: 6,
+	}
+	v.width, v.height = ttySize()
+	v.draw(out)
+
+	samples := make(chan topSample, 1)
+	go topSnapshots(ctx, cfg, db, filter, redactor, samples)
+	keys := make(chan []byte)
+	go topKeys(keys)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-winch:
+			v.width, v.height = ttySize()
+		case s := <-samples:
+			if !v.paused {
+				v.update(s)
+			}
+		case k, ok := <-keys:
+			if !ok || v.key(k) {
+				return nil
+			}
+		}
+		v.draw(out)
+	}
+}
+
+// topSnapshots takes a snapshot every interval and sends the filtered, redacted delta.
+func topSnapshots(ctx context.Context, cfg Config, db DBClient, filter DigestFilter, red Redactor, out chan<- topSample) {
+	send := func(s topSample) {
+		select {
+		case out <- s:
+		case <-ctx.Done():
+		}
+	}
+	prev, err := db.Snapshot(ctx)
+	if err != nil {
+		send(topSample{err: err})
+		return
+	}
+	prevAt := time.Now()
+	filter.Refresh(ctx, db, prevAt)
+	t := time.NewTicker(cfg.Interval())
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		now := time.Now()
+		curr, err := db.Snapshot(ctx)
+		if err != nil {
+			send(topSample{at: now, err: err})
+			continue
+		}
+		delta := deltaSnap(prev, curr)
+		filter.Refresh(ctx, db, now)
+		filter.Filter(delta)
+		redactStats(red, delta)
+		send(topSample{at: now, elapsed: now.Sub(prevAt), delta: delta})
+		prev, prevAt = curr, now
+	}
+}
+
+// topKeys forwards raw keyboard input; each read is one key or escape sequence.
+func topKeys(out chan<- []byte) {
+	defer close(out)
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		out <- append([]byte(nil), buf[:n]...)
+	}
+}
+
+func (v *topView) update(s topSample) {
+	v.at, v.err = s.at, s.err
+	if s.err != nil {
+		return
+	}
+	v.elapsed = s.elapsed
+	var selected snapKey
+	if rows := v.visible(); v.selected < len(rows) {
+		selected = rows[v.selected].key
+	}
+	v.rows = v.rows[:0]
+	secs := s.elapsed.Seconds()
+	for k, d := range s.delta {
+		r := topRow{
+			key:      k,
+			schema:   d.Schema,
+			digest:   d.Digest,
+			sample:   sampleText(d),
+			count:    d.CountStar,
+			examined: d.SumRowsExam,
+			sent:     d.SumRowsSent,
+			affected: d.SumRowsAff,
+			bytes:    d.SumRowsExam*v.avgRead + d.SumRowsSent*v.avgSent,
+		}
+		if secs > 0 {
+			r.rate = float64(r.bytes) / secs
+		}
+		v.rows = append(v.rows, r)
+	}
+	// Every known digest gets a point per interval, zero when idle; fully idle histories are dropped.
+	for k := range v.history {
+		if _, ok := s.delta[k]; !ok {
+			v.push(k, 0)
+		}
+	}
+	for _, r := range v.rows {
+		v.push(r.key, r.bytes)
+	}
+	for k, h := range v.history {
+		idle := true
+		for _, x := range h {
+			idle = idle && x == 0
+		}
+		if idle {
+			delete(v.history, k)
+		}
+	}
+	v.sort()
+	v.selected = 0
+	for i, r := range v.visible() {
+		if r.key == selected {
+			v.selected = i
+		}
+	}
+}
+
+func (v *topView) push(k snapKey, x uint64) {
+	h := append(v.history[k], x)
+	if len(h) > topHistory {
+		h = h[len(h)-topHistory:]
+	}
+	v.history[k] = h
+}
+
+func (v *topView) sort() {
+	key := func(r topRow) float64 {
+		switch v.sortCol {
+		case 2:
+			return float64(r.count)
+		case 3:
+			return float64(r.examined)
+		case 4:
+			return float64(r.sent)
+		case 5:
+			return float64(r.affected)
+		case 7:
+			return r.rate
+		default:
+			return float64(r.bytes)
+		}
+	}
+	less := func(a, b topRow) bool {
+		switch v.sortCol {
+		case 0:
+			return a.digest < b.digest
+		case 1:
+			return a.schema < b.schema || a.schema == b.schema && a.digest < b.digest
+		default:
+			return key(a) < key(b)
+		}
+	}
+	sort.SliceStable(v.rows, func(i, j int) bool {
+		if v.asc {
+			return less(v.rows[i], v.rows[j])
+		}
+		return less(v.rows[j], v.rows[i])
+	})
+}
+
+// visible returns the rows that pass the schema filter.
+func (v *topView) visible() []topRow {
+	if v.schema == "" {
+		return v.rows
+	}
+	var out []topRow
+	for _, r := range v.rows {
+		if strings.EqualFold(r.schema, v.schema) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// key handles one keypress and reports whether to quit.
+func (v *topView) key(k []byte) bool {
+	if v.prompt != nil {
+		switch {
+		case len(k) == 1 && k[0] == '\r':
+			v.schema, v.prompt, v.selected = strings.TrimSpace(*v.prompt), nil, 0
+		case len(k) == 1 && k[0] == 0x1b:
+			v.prompt = nil
+		case len(k) == 1 && (k[0] == 0x7f || k[0] == 0x08):
+			if p := *v.prompt; p != "" {
+				*v.prompt = p[:len(p)-1]
+			}
+		case len(k) == 1 && k[0] == 0x03:
+			return true
+		default:
+			for _, c := range k {
+				if c >= 0x20 && c < 0x7f {
+					*v.prompt += string(c)
+				}
+			}
+		}
+		return false
+	}
+	n := len(v.visible())
+	switch s := string(k); {
+	case s == "q" || s == "Q" || s == "\x03":
+		return true
+	case s == "p" || s == "P" || s == " ":
+		v.paused = !v.paused
+	case len(s) == 1 && s[0] >= '1' && s[0] < '1'+topColumns:
+		col := int(s[0] - '1')
+		if col == v.sortCol {
+			v.asc = !v.asc
+		} else {
+			// Names sort A-Z by default, numbers largest first
+			v.sortCol, v.asc = col, col < 2
+		}
+		v.sort()
+	case s == "/":
+		p := v.schema
+		v.prompt = &p
+	case s == "\r":
+		v.detail = !v.detail
+	case s == "\x1b":
+		v.detail = false
+	case s == "\x1b[A" || s == "k":
+		v.selected = max(v.selected-1, 0)
+	case s == "\x1b[B" || s == "j":
+		v.selected = max(min(v.selected+1, n-1), 0)
+	}
+	return false
+}
+
+func (v *topView) draw(w *bufio.Writer) {
+	var lines []string
+	state := ""
+	if v.paused {
+		state = "  \x1b[7m PAUSED \x1b[0m"
+	}
+	when := "waiting for first interval…"
+	if !v.at.IsZero() {
+		when = v.at.Format("15:04:05") + "  interval " + v.elapsed.Round(time.Millisecond).String()
+	}
+	lines = append(lines, fmt.Sprintf("\x1b[1mtop\x1b[0m  %s  %s%s", v.target, when, state))
+
+	rows := v.visible()
+	var total topRow
+	for _, r := range rows {
+		total.count += r.count
+		total.bytes += r.bytes
+		total.rate += r.rate
+	}
+	filter := "all schemas"
+	if v.schema != "" {
+		filter = "schema " + v.schema
+	}
+	dir := "desc"
+	if v.asc {
+		dir = "asc"
+	}
+	lines = append(lines, fmt.Sprintf("%d digests (%s), %d executions, est. %s (%s/s)  sort: %s %s",
+		len(rows), filter, total.count, bytesToHuman(total.bytes), bytesToHuman(uint64(total.rate)), topColumnNames[v.sortCol], dir))
+	if v.err != nil {
+		lines = append(lines, "\x1b[31msnapshot failed: "+termSafe(v.err.Error())+"\x1b[0m")
+	} else {
+		lines = append(lines, "")
+	}
+
+	if v.detail && v.selected < len(rows) {
+		r := rows[v.selected]
+		lines = append(lines,
+			"\x1b[1mdigest\x1b[0m   "+termSafe(r.digest),
+			"\x1b[1mschema\x1b[0m   "+termSafe(r.schema),
+			fmt.Sprintf("\x1b[1mcount\x1b[0m    %d   examined %d   sent %d   affected %d", r.count, r.examined, r.sent, r.affected),
+			fmt.Sprintf("\x1b[1mest.\x1b[0m     %s (%s/s)   %s", bytesToHuman(r.bytes), bytesToHuman(uint64(r.rate)), sparkline(v.history[r.key])),
+			"",
+		)
+		for _, l := range strings.Split(wrapText(r.sample, max(v.width, 20)), "\n") {
+			lines = append(lines, termSafe(l))
+		}
+		lines = append(lines, "", "esc/enter back")
+	} else {
+		var header strings.Builder
+		for i, name := range topColumnNames {
+			header.WriteString(topCell(fmt.Sprintf("%d %s", i+1, name), topWidths[i], i < 2))
+			header.WriteString("  ")
+		}
+		fmt.Fprintf(&header, "%-*s  QUERY", topHistory, "HISTORY")
+		line := clip(header.String(), v.width)
+		// Highlight the sort column in place, after clipping so widths stay right
+		label := strings.TrimSpace(topCell(fmt.Sprintf("%d %s", v.sortCol+1, topColumnNames[v.sortCol]), topWidths[v.sortCol], v.sortCol < 2))
+		line = strings.Replace(line, label, "\x1b[7m"+label+"\x1b[27m", 1)
+		lines = append(lines, "\x1b[1m"+line+"\x1b[0m")
+		room := max(v.height-len(lines)-2, 1)
+		first := 0
+		if v.selected >= room {
+			first = v.selected - room + 1
+		}
+		for i := first; i < len(rows) && i < first+room; i++ {
+			r := rows[i]
+			cells := [topColumns]string{
+				termSafe(shortDigest(r.digest)), termSafe(r.schema),
+				strconv.FormatUint(r.count, 10), strconv.FormatUint(r.examined, 10),
+				strconv.FormatUint(r.sent, 10), strconv.FormatUint(r.affected, 10),
+				bytesToHuman(r.bytes), bytesToHuman(uint64(r.rate)),
+			}
+			var b strings.Builder
+			for c, cell := range cells {
+				b.WriteString(topCell(cell, topWidths[c], c < 2))
+				b.WriteString("  ")
+			}
+			fmt.Fprintf(&b, "%-*s  %s", topHistory, sparkline(v.history[r.key]), termSafe(strings.Join(strings.Fields(r.sample), " ")))
+			line := clip(b.String(), v.width)
+			if i == v.selected {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	w.WriteString("\x1b[H\x1b[2J")
+	for i, l := range lines {
+		if v.height > 1 && i >= v.height-1 {
+			break
+		}
+		w.WriteString(l)
+		w.WriteString("\r\n")
+	}
+	if v.prompt != nil {
+		fmt.Fprintf(w, "\x1b[%d;1Hschema filter (empty = all): %s\x1b[?25h", max(v.height, 1), *v.prompt)
+	} else {
+		fmt.Fprintf(w, "\x1b[%d;1H\x1b[2m%s\x1b[0m\x1b[?25l", max(v.height, 1), clip(fmt.Sprintf(topFooterFmt, topColumns), v.width))
+	}
+	w.Flush()
+}
+
+// topCell pads (or cuts) s to width, left- or right-aligned.
+func topCell(s string, width int, left bool) string {
+	if r := []rune(s); len(r) > width {
+		s = string(r[:width-1]) + "…"
+	}
+	if left {
+		return fmt.Sprintf("%-*s", width, s)
+	}
+	return fmt.Sprintf("%*s", width, s)
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline scales values to the block characters, relative to their maximum.
+func sparkline(values []uint64) string {
+	var peak uint64
+	for _, x := range values {
+		peak = max(peak, x)
+	}
+	var b strings.Builder
+	for _, x := range values {
+		switch {
+		case x == 0:
+			b.WriteRune(' ')
+		case peak == 0:
+			b.WriteRune(sparkBlocks[0])
+		default:
+			b.WriteRune(sparkBlocks[int(x*uint64(len(sparkBlocks)-1)/peak)])
+		}
+	}
+	return b.String()
+}
+
+// clip cuts s to width runes; 0 means unknown width.
+func clip(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width])
+}
+
+// termSafe replaces control characters (C0 including newlines, DEL, C1) and
+// invalid UTF-8 with U+FFFD, so query text from any DB client cannot inject
+// escape sequences into the operator's terminal. Tabs become spaces.
+func termSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case r < 0x20, r >= 0x7f && r <= 0x9f:
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
+
+// wrapText hard-wraps s at width runes, keeping existing line breaks.
+func wrapText(s string, width int) string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		r := []rune(strings.TrimRight(line, "\r"))
+		for len(r) > width {
+			out = append(out, string(r[:width]))
+			r = r[width:]
+		}
+		out = append(out, string(r))
+	}
+	return strings.Join(out, "\n")
+}
+
+// ttyRaw switches the controlling terminal to raw mode and returns a restore function.
+func ttyRaw() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { _, _ = stty(strings.TrimSpace(saved)) }, nil
+}
+
+// ttySize returns the terminal width and height, falling back to 80x24.
+func ttySize() (int, int) {
+	out, err := stty("size")
+	if err != nil {
+		return 80, 24
+	}
+	f := strings.Fields(out)
+	if len(f) != 2 {
+		return 80, 24
+	}
+	rows, err1 := strconv.Atoi(f[0])
+	cols, err2 := strconv.Atoi(f[1])
+	if err1 != nil || err2 != nil || rows <= 0 || cols <= 0 {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("stty: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestTermSafe(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = ?"},
+		{"SELECT 'ünïcødé' → ok", "SELECT 'ünïcødé' → ok"},
+		{"a\tb", "a b"},
+		{"SELECT '\x1b[2J\x1b]0;pwned\x07'", "SELECT '�[2J�]0;pwned�'"},
+		{"line1\nline2\r", "line1�line2�"},
+		{"del\x7f", "del�"},
+		{"c1 \u009b31m csi", "c1 �31m csi"},
+		{"raw \x9b byte", "raw � byte"},
+		{"nul\x00", "nul�"},
+	} {
+		if got := termSafe(tc.in); got != tc.want {
+			t.Errorf("termSafe(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTopDrawStripsControlSequences(t *testing.T) {
+	evil := "SELECT 1 /* \x1b]52;c;cHduZWQ=\x07 \x1b[31m */\nFROM dual \u009b2J"
+	for _, detail := range []bool{false, true} {
+		v := &topView{
+			width: 200, height: 40, detail: detail,
+			rows: []topRow{{key: newSnapKey("s\x1b[5m", "d1"), schema: "s\x1b[5m", digest: "d1", sample: evil}},
+		}
+		var out strings.Builder
+		w := bufio.NewWriter(&out)
+		v.draw(w)
+		for _, bad := range []string{"\x1b]52", "\x1b[31m", "\x1b[5m", "\x07", "\u009b"} {
+			if strings.Contains(out.String(), bad) {
+				t.Errorf("detail=%v: output contains %q", detail, bad)
+			}
+		}
+		if !strings.Contains(out.String(), "�]52;c;cHduZWQ=�") {
+			t.Errorf("detail=%v: sample not rendered with placeholders", detail)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package main
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 func parseBytesFlag(s string) (uint64, error) {
@@ -55,24 +59,34 @@ func deltaSnap(oldSnap, newSnap snapshot) map[snapKey]digestStat {
 			if newv.SumRowsSent >= oldv.SumRowsSent {
 				dRowsSent = newv.SumRowsSent - oldv.SumRowsSent
 			}
-			if dCount == 0 && dRowsExam == 0 && dRowsSent == 0 {
+			dRowsAff := uint64(0)
+			if newv.SumRowsAff >= oldv.SumRowsAff {
+				dRowsAff = newv.SumRowsAff - oldv.SumRowsAff
+			}
+			if dCount == 0 && dRowsExam == 0 && dRowsSent == 0 && dRowsAff == 0 {
 				continue
 			}
 			out[k] = digestStat{
+				Schema:      newv.Schema,
 				Digest:      newv.Digest,
 				DigestText:  newv.DigestText,
+				QuerySample: newv.QuerySample,
 				CountStar:   dCount,
 				SumRowsExam: dRowsExam,
 				SumRowsSent: dRowsSent,
+				SumRowsAff:  dRowsAff,
 			}
 		} else {
 			// new digest, treat entire counts as delta
 			out[k] = digestStat{
+				Schema:      newv.Schema,
 				Digest:      newv.Digest,
 				DigestText:  newv.DigestText,
+				QuerySample: newv.QuerySample,
 				CountStar:   newv.CountStar,
 				SumRowsExam: newv.SumRowsExam,
 				SumRowsSent: newv.SumRowsSent,
+				SumRowsAff:  newv.SumRowsAff,
 			}
 		}
 	}
@@ -109,6 +123,48 @@ func trimSpaceUpper(s string) string  { return strings.ToUpper(strings.TrimSpace
 func hasSuffix(s, suf string) bool    { return strings.HasSuffix(s, suf) }
 func trimSuffix(s, suf string) string { return strings.TrimSuffix(s, suf) }
 
+// roundTo rounds f to the given number of decimal places (for log output)
+func roundTo(f float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(f*p) / p
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into
+// place, so a crash never leaves a truncated state file behind.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// dsnTarget returns a credential-free identifier of the monitored server
+// ("host:port/db") for labels and logs.
+func dsnTarget(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "unknown"
+	}
+	if cfg.DBName != "" {
+		return cfg.Addr + "/" + cfg.DBName
+	}
+	return cfg.Addr
+}
+
 // Rank helper
 func maxU64(a, b uint64) uint64 {
 	if a > b {